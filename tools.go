@@ -9,10 +9,11 @@ import (
 	"io"
 	"net/http"
 	"os"
-	"path"
 	"path/filepath"
 	"regexp"
 	"strings"
+	"unicode"
+	"unicode/utf8"
 )
 
 // randomStringSource is the source of characters for the string to be generated.
@@ -191,11 +192,139 @@ func (t *Tools) Slugify(s string) (string, error) {
 }
 
 // DownloadStaticFile lets the user download a particular file saved on the server with the particular path given to it and the
-// downloaded file can be of the given name.
+// downloaded file can be of the given name. Files resolving outside of p are refused with a JSON 403 and missing files get a
+// JSON 404.
 func (t *Tools) DownloadStaticFile(w http.ResponseWriter, r *http.Request, p, file, displayName string) {
-	fp := path.Join(p, file)
-	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s\"", displayName))
-	http.ServeFile(w, r, fp)
+	f, info, err := openInBase(p, file)
+	if err != nil {
+		if errors.Is(err, errPathEscapesRoot) {
+			_ = t.ErrorJSON(w, errors.New("access to the requested file is forbidden"), http.StatusForbidden)
+		} else {
+			_ = t.ErrorJSON(w, errors.New("the requested file does not exist"), http.StatusNotFound)
+		}
+		return
+	}
+	defer f.Close()
+
+	w.Header().Set("Content-Disposition", attachmentDisposition(displayName))
+	http.ServeContent(w, r, info.Name(), info.ModTime(), f)
+}
+
+// openInBase opens the file name inside the base directory, as resolved by resolvePath. The name is resolved again once
+// the file is open and must still lead to it, so a symbolic link swapped in between resolving and opening can not lead
+// outside of base.
+func openInBase(base, name string) (*os.File, os.FileInfo, error) {
+	fp, err := resolvePath(base, name)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	f, err := os.Open(fp)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	info, err := f.Stat()
+	if err != nil || info.IsDir() {
+		f.Close()
+		return nil, nil, os.ErrNotExist
+	}
+
+	again, err := resolvePath(base, name)
+	if err != nil {
+		f.Close()
+		return nil, nil, err
+	}
+
+	if current, err := os.Stat(again); err != nil || !os.SameFile(info, current) {
+		f.Close()
+		return nil, nil, errPathEscapesRoot
+	}
+
+	return f, info, nil
+}
+
+// attachmentDisposition returns the Content-Disposition header of an attachment named filename, following RFC 6266. Names
+// which are not plain ASCII get an ASCII fallback with the offending characters replaced by "_", along with their exact
+// UTF-8 form in a filename* parameter.
+func attachmentDisposition(filename string) string {
+	var fallback strings.Builder
+	needsExtended := false
+	for _, c := range filename {
+		switch {
+		case c < 0x20 || c == 0x7f || c == '"' || c == '\\' || c > unicode.MaxASCII:
+			fallback.WriteByte('_')
+			needsExtended = true
+		default:
+			fallback.WriteRune(c)
+		}
+	}
+
+	value := fmt.Sprintf("attachment; filename=\"%s\"", fallback.String())
+	if needsExtended && utf8.ValidString(filename) {
+		value += "; filename*=UTF-8''" + encodeRFC5987(filename)
+	}
+
+	return value
+}
+
+// encodeRFC5987 percent-encodes every byte of s which is not an attr-char as defined by RFC 5987.
+func encodeRFC5987(s string) string {
+	const hex = "0123456789ABCDEF"
+
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if ('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z') || ('0' <= c && c <= '9') || strings.IndexByte("!#$&+-.^_`|~", c) >= 0 {
+			b.WriteByte(c)
+			continue
+		}
+		b.WriteByte('%')
+		b.WriteByte(hex[c>>4])
+		b.WriteByte(hex[c&0x0f])
+	}
+
+	return b.String()
+}
+
+// errPathEscapesRoot is returned when a requested file resolves to a location outside of its base directory.
+var errPathEscapesRoot = errors.New("path escapes the base directory")
+
+// resolvePath returns the path of name inside the base directory, rejecting names which would escape it directly or through
+// a symbolic link.
+func resolvePath(base, name string) (string, error) {
+	name = filepath.FromSlash(name)
+	if strings.ContainsRune(name, 0) || !filepath.IsLocal(name) {
+		return "", errPathEscapesRoot
+	}
+
+	root, err := filepath.EvalSymlinks(base)
+	if err != nil {
+		return "", err
+	}
+	root, err = filepath.Abs(root)
+	if err != nil {
+		return "", err
+	}
+
+	resolved, err := filepath.EvalSymlinks(filepath.Join(root, name))
+	if err != nil {
+		return "", err
+	}
+	resolved, err = filepath.Abs(resolved)
+	if err != nil {
+		return "", err
+	}
+
+	if rel, err := filepath.Rel(root, resolved); err != nil || !filepath.IsLocal(rel) {
+		return "", errPathEscapesRoot
+	}
+
+	if info, err := os.Stat(resolved); err != nil || info.IsDir() {
+		return "", os.ErrNotExist
+	}
+
+	return resolved, nil
 }
 
 // JSONResponse is the type used for sending JSON around
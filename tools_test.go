@@ -265,6 +265,65 @@ func TestTools_DownloadStaticFile(t *testing.T) {
 	}
 }
 
+var attachmentDispositionTests = []struct {
+	name     string
+	filename string
+	want     string
+}{
+	{name: "plain", filename: "rowdy-cat.jpg", want: `attachment; filename="rowdy-cat.jpg"`},
+	{name: "quote", filename: `say "hi".txt`, want: `attachment; filename="say _hi_.txt"; filename*=UTF-8''say%20%22hi%22.txt`},
+	{name: "header injection", filename: "a\r\nSet-Cookie: x.txt", want: `attachment; filename="a__Set-Cookie: x.txt"; filename*=UTF-8''a%0D%0ASet-Cookie%3A%20x.txt`},
+	{name: "unicode", filename: "Grüße.pdf", want: `attachment; filename="Gr__e.pdf"; filename*=UTF-8''Gr%C3%BC%C3%9Fe.pdf`},
+}
+
+func TestTools_DownloadStaticFileDisposition(t *testing.T) {
+	var testTools Tools
+
+	for _, e := range attachmentDispositionTests {
+		rr := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/", nil)
+
+		testTools.DownloadStaticFile(rr, req, "testdata", "pic.jpg", e.filename)
+
+		if got := rr.Header().Get("Content-Disposition"); got != e.want {
+			t.Errorf("%s: expected %s but got %s", e.name, e.want, got)
+		}
+	}
+}
+
+var downloadTraversalTests = []struct {
+	name       string
+	file       string
+	statusCode int
+}{
+	{name: "parent traversal", file: "../tools.go", statusCode: http.StatusForbidden},
+	{name: "deep traversal", file: "../../../../../../etc/passwd", statusCode: http.StatusForbidden},
+	{name: "hidden traversal", file: "uploads/../../tools.go", statusCode: http.StatusForbidden},
+	{name: "absolute path", file: "/etc/passwd", statusCode: http.StatusForbidden},
+	{name: "missing file", file: "nope.jpg", statusCode: http.StatusNotFound},
+	{name: "directory", file: ".", statusCode: http.StatusNotFound},
+}
+
+func TestTools_DownloadStaticFileTraversal(t *testing.T) {
+	var testTools Tools
+
+	for _, e := range downloadTraversalTests {
+		rr := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/", nil)
+
+		testTools.DownloadStaticFile(rr, req, "testdata", e.file, "rowdy-cat.jpg")
+
+		if rr.Code != e.statusCode {
+			t.Errorf("%s: expected status %d but got %d", e.name, e.statusCode, rr.Code)
+		}
+
+		var payload JSONResponse
+		if err := json.NewDecoder(rr.Body).Decode(&payload); err != nil || !payload.Error {
+			t.Errorf("%s: expected a JSON error response", e.name)
+		}
+	}
+}
+
 var jsonTests = []struct {
 	name          string
 	json          string
@@ -0,0 +1,235 @@
+package toolkit
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// ArchiveFormat is the format of the archives built by DownloadArchive.
+type ArchiveFormat int
+
+const (
+	// ArchiveZip builds a zip archive. This is the default.
+	ArchiveZip ArchiveFormat = iota
+	// ArchiveTarGz builds a gzip compressed tar archive.
+	ArchiveTarGz
+)
+
+// ArchiveFile is a file to be added to an archive by DownloadArchive.
+type ArchiveFile struct {
+	// FS is the file system holding the file. When nil, Path is a path on the OS file system.
+	FS fs.FS
+	// Path is the path of the file, inside FS when it is set.
+	Path string
+	// Name is the name of the file inside the archive. It defaults to the base name of Path.
+	Name string
+}
+
+// DownloadArchive streams files to the client as a single archive named displayName, without building it on disk first.
+// Every file is checked before anything is sent, so a missing file still gets a JSON 404. Duplicate names inside the
+// archive get a numbered suffix. If the client goes away or a file fails while streaming, the archive is left unfinished
+// and the error is returned.
+func (t *Tools) DownloadArchive(w http.ResponseWriter, r *http.Request, files []ArchiveFile, displayName string, format ...ArchiveFormat) error {
+	w = t.throttleDownload(w, r)
+
+	archiveFormat := ArchiveZip
+	if len(format) > 0 {
+		archiveFormat = format[0]
+	}
+
+	for _, f := range files {
+		if _, err := f.stat(); err != nil {
+			t.downloadError(w, err)
+			return err
+		}
+	}
+
+	names := archiveNames(files)
+
+	switch archiveFormat {
+	case ArchiveZip:
+		w.Header().Set("Content-Type", "application/zip")
+		t.setDownloadHeaders(w, displayName)
+		return writeZip(r.Context(), w, files, names)
+
+	case ArchiveTarGz:
+		w.Header().Set("Content-Type", "application/gzip")
+		t.setDownloadHeaders(w, displayName)
+		return writeTarGz(r.Context(), w, files, names)
+
+	default:
+		err := errors.New("unknown archive format")
+		_ = t.ErrorJSON(w, err, http.StatusInternalServerError)
+		return err
+	}
+}
+
+// open opens the file described by f.
+func (f ArchiveFile) open() (fs.File, error) {
+	if f.FS != nil {
+		return f.FS.Open(f.Path)
+	}
+
+	return os.Open(f.Path)
+}
+
+// stat returns the file info of the file described by f, refusing directories.
+func (f ArchiveFile) stat() (fs.FileInfo, error) {
+	var info fs.FileInfo
+	var err error
+
+	if f.FS != nil {
+		info, err = fs.Stat(f.FS, f.Path)
+	} else {
+		info, err = os.Stat(f.Path)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if info.IsDir() {
+		return nil, fmt.Errorf("%s is a directory: %w", f.Path, os.ErrNotExist)
+	}
+
+	return info, nil
+}
+
+// archiveNames returns the name of every file inside the archive. Names are made relative, so the archive can not be used
+// to write outside of the directory it is extracted in, and made unique by adding " (n)" before the extension.
+func archiveNames(files []ArchiveFile) []string {
+	names := make([]string, len(files))
+	used := make(map[string]bool)
+
+	for i, f := range files {
+		name := f.Name
+		if name == "" {
+			name = filepath.Base(f.Path)
+		}
+
+		name = strings.TrimLeft(path.Clean("/"+filepath.ToSlash(name)), "/")
+		if name == "" {
+			name = "file"
+		}
+
+		candidate := name
+		ext := path.Ext(name)
+		for n := 1; used[strings.ToLower(candidate)]; n++ {
+			candidate = fmt.Sprintf("%s (%d)%s", strings.TrimSuffix(name, ext), n, ext)
+		}
+
+		used[strings.ToLower(candidate)] = true
+		names[i] = candidate
+	}
+
+	return names
+}
+
+// writeZip writes files to w as a zip archive.
+func writeZip(ctx context.Context, w io.Writer, files []ArchiveFile, names []string) error {
+	zw := zip.NewWriter(w)
+
+	for i, f := range files {
+		err := func() error {
+			in, err := f.open()
+			if err != nil {
+				return err
+			}
+			defer in.Close()
+
+			info, err := in.Stat()
+			if err != nil {
+				return err
+			}
+
+			hdr, err := zip.FileInfoHeader(info)
+			if err != nil {
+				return err
+			}
+			hdr.Name = names[i]
+			hdr.Method = zip.Deflate
+
+			out, err := zw.CreateHeader(hdr)
+			if err != nil {
+				return err
+			}
+
+			_, err = io.Copy(out, contextReader{ctx: ctx, r: in})
+			return err
+		}()
+		if err != nil {
+			return err
+		}
+	}
+
+	return zw.Close()
+}
+
+// writeTarGz writes files to w as a gzip compressed tar archive.
+func writeTarGz(ctx context.Context, w io.Writer, files []ArchiveFile, names []string) error {
+	gw := gzip.NewWriter(w)
+	tw := tar.NewWriter(gw)
+
+	for i, f := range files {
+		err := func() error {
+			in, err := f.open()
+			if err != nil {
+				return err
+			}
+			defer in.Close()
+
+			info, err := in.Stat()
+			if err != nil {
+				return err
+			}
+
+			hdr := &tar.Header{
+				Typeflag: tar.TypeReg,
+				Name:     names[i],
+				Size:     info.Size(),
+				Mode:     0644,
+				ModTime:  info.ModTime(),
+			}
+			if err = tw.WriteHeader(hdr); err != nil {
+				return err
+			}
+
+			_, err = io.Copy(tw, contextReader{ctx: ctx, r: in})
+			return err
+		}()
+		if err != nil {
+			return err
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return err
+	}
+
+	return gw.Close()
+}
+
+// contextReader is an io.Reader which stops reading once its context is done.
+type contextReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+// Read reads from the underlying reader, unless the context is done.
+func (c contextReader) Read(p []byte) (int, error) {
+	if err := c.ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	return c.r.Read(p)
+}
@@ -0,0 +1,97 @@
+package toolkit
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"testing/fstest"
+)
+
+var archiveFiles = []ArchiveFile{
+	{Path: "./testdata/pic.jpg"},
+	{Path: "./testdata/img.jpg", Name: "pic.jpg"},
+	{FS: fstest.MapFS{"notes/a.txt": {Data: []byte("hello")}}, Path: "notes/a.txt", Name: "../../a.txt"},
+}
+
+var archiveExpectedNames = []string{"pic.jpg", "pic (1).jpg", "a.txt"}
+
+func TestTools_DownloadArchive(t *testing.T) {
+	var testTools Tools
+
+	for _, format := range []ArchiveFormat{ArchiveZip, ArchiveTarGz} {
+		rr := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/", nil)
+
+		if err := testTools.DownloadArchive(rr, req, archiveFiles, "all.zip", format); err != nil {
+			t.Fatal(err)
+		}
+
+		if rr.Header().Get("Content-Disposition") != `attachment; filename="all.zip"` {
+			t.Errorf("wrong content disposition: %s", rr.Header().Get("Content-Disposition"))
+		}
+
+		var names []string
+		if format == ArchiveZip {
+			zr, err := zip.NewReader(bytes.NewReader(rr.Body.Bytes()), int64(rr.Body.Len()))
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, f := range zr.File {
+				names = append(names, f.Name)
+			}
+		} else {
+			gr, err := gzip.NewReader(rr.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			tr := tar.NewReader(gr)
+			for {
+				hdr, err := tr.Next()
+				if err == io.EOF {
+					break
+				}
+				if err != nil {
+					t.Fatal(err)
+				}
+				names = append(names, hdr.Name)
+			}
+		}
+
+		if len(names) != len(archiveExpectedNames) {
+			t.Fatalf("expected %d files but got %v", len(archiveExpectedNames), names)
+		}
+		for i, name := range names {
+			if name != archiveExpectedNames[i] {
+				t.Errorf("expected name %s but got %s", archiveExpectedNames[i], name)
+			}
+		}
+	}
+}
+
+func TestTools_DownloadArchiveErrors(t *testing.T) {
+	var testTools Tools
+
+	rr := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+
+	err := testTools.DownloadArchive(rr, req, []ArchiveFile{{Path: "./testdata/nope.jpg"}}, "all.zip")
+	if err == nil || rr.Code != http.StatusNotFound {
+		t.Errorf("expected a 404 for a missing file, got %d", rr.Code)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	rr = httptest.NewRecorder()
+	req, _ = http.NewRequestWithContext(ctx, "GET", "/", nil)
+
+	if err := testTools.DownloadArchive(rr, req, archiveFiles, "all.zip"); err == nil {
+		t.Error("expected an error when the client is gone")
+	}
+}
@@ -0,0 +1,351 @@
+package toolkit
+
+import (
+	"encoding"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	timeType     = reflect.TypeOf(time.Time{})
+	durationType = reflect.TypeOf(time.Duration(0))
+)
+
+// bindSource is a part of a request parameters can be read from, such as its query string.
+type bindSource struct {
+	// tag is the struct tag naming the parameter of a field.
+	tag string
+	// label names the source in error messages.
+	label string
+	// lookup returns the values of the parameter called name.
+	lookup func(name string) []string
+}
+
+// querySource reads parameters from the query string of r.
+func querySource(r *http.Request) bindSource {
+	query := r.URL.Query()
+	return bindSource{tag: "query", label: "query parameter", lookup: func(name string) []string { return query[name] }}
+}
+
+// formSource reads parameters from the parsed form body of r.
+func formSource(r *http.Request) bindSource {
+	return bindSource{tag: "form", label: "form field", lookup: func(name string) []string { return r.PostForm[name] }}
+}
+
+// pathSource reads parameters from the wildcards of the ServeMux pattern matched by r.
+func pathSource(r *http.Request) bindSource {
+	return bindSource{tag: "path", label: "path value", lookup: func(name string) []string {
+		if v := r.PathValue(name); v != "" {
+			return []string{v}
+		}
+		return nil
+	}}
+}
+
+// ReadQuery populates data, which must be a pointer to a struct, from the query string of r. Fields are matched by their
+// query tag, as in `query:"page"`, and untagged nested structs are filled the same way. See Bind for the conversions.
+func (t *Tools) ReadQuery(r *http.Request, data interface{}) error {
+	return bind(data, querySource(r))
+}
+
+// ReadForm populates data, which must be a pointer to a struct, from the URL-encoded or multipart form body of r, limited
+// to MaxJsonSize bytes, or 1 MB. Fields are matched by their form tag, as in `form:"email"`. See Bind for the conversions.
+func (t *Tools) ReadForm(w http.ResponseWriter, r *http.Request, data interface{}) error {
+	if err := t.parseForm(w, r); err != nil {
+		return err
+	}
+
+	return bind(data, formSource(r))
+}
+
+// Bind populates data, which must be a pointer to a struct, from the query string, the form body when there is one, and
+// the path values of r, as matched by a Go 1.22 ServeMux pattern. Fields are matched by their query, form and path tags,
+// and when a field has several of them, path values win over form fields, which win over the query string.
+//
+// Values are converted to strings, bools, ints, uints, floats, time.Duration, time.Time (RFC 3339 or 2006-01-02), any
+// encoding.TextUnmarshaler, pointers to those, and slices of those, which take either repeated parameters or a single
+// comma separated one. Missing or empty parameters leave the field untouched, or set it from its default tag, as in
+// `query:"per_page" default:"20"`. The first value which can not be converted is returned as a JSONError of kind
+// JSONErrType, with the name of the parameter as Field.
+func (t *Tools) Bind(w http.ResponseWriter, r *http.Request, data interface{}) error {
+	sources := []bindSource{querySource(r)}
+
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType == "application/x-www-form-urlencoded" || mediaType == "multipart/form-data" {
+		if err := t.parseForm(w, r); err != nil {
+			return err
+		}
+		sources = append(sources, formSource(r))
+	}
+
+	sources = append(sources, pathSource(r))
+
+	return bind(data, sources...)
+}
+
+// parseForm parses the form body of r, limited to MaxJsonSize bytes, or 1 MB.
+func (t *Tools) parseForm(w http.ResponseWriter, r *http.Request) error {
+	if r.PostForm != nil {
+		return nil
+	}
+
+	maxBytes := t.maxJSONBytes()
+	r.Body = http.MaxBytesReader(w, r.Body, int64(maxBytes))
+
+	var err error
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType == "multipart/form-data" {
+		err = r.ParseMultipartForm(int64(maxBytes))
+	} else {
+		err = r.ParseForm()
+	}
+
+	if err != nil {
+		var maxBytesError *http.MaxBytesError
+		if errors.As(err, &maxBytesError) {
+			return &JSONError{
+				Kind:   JSONErrTooLarge,
+				Key:    "too_large",
+				Params: map[string]interface{}{"max_bytes": maxBytes},
+				Err:    err,
+				msg:    fmt.Sprintf("body must not be larger than %d bytes", maxBytes),
+			}
+		}
+		return &JSONError{Kind: JSONErrSyntax, Key: "bad_form", Err: err, msg: "body contains a badly-formed form"}
+	}
+
+	return nil
+}
+
+// bind populates the struct data points to from sources.
+func bind(data interface{}, sources ...bindSource) error {
+	v := reflect.ValueOf(data)
+	if v.Kind() != reflect.Pointer || v.IsNil() || v.Elem().Kind() != reflect.Struct {
+		return &JSONError{Kind: JSONErrInvalidTarget, msg: "bind target must be a non-nil pointer to a struct"}
+	}
+
+	return bindStruct(v.Elem(), sources)
+}
+
+// bindStruct populates the fields of the struct v from sources.
+func bindStruct(v reflect.Value, sources []bindSource) error {
+	for i := 0; i < v.NumField(); i++ {
+		sf := v.Type().Field(i)
+		if !sf.IsExported() {
+			continue
+		}
+		fv := v.Field(i)
+
+		var name string
+		var source bindSource
+		var values []string
+		tagged := false
+
+		for _, src := range sources {
+			tag, ok := sf.Tag.Lookup(src.tag)
+			if !ok || tag == "-" {
+				continue
+			}
+			tagged = true
+
+			n, _, _ := strings.Cut(tag, ",")
+			if name == "" {
+				name, source = n, src
+			}
+			if found := src.lookup(n); len(found) > 0 && !(len(found) == 1 && found[0] == "") {
+				name, source, values = n, src, found
+			}
+		}
+
+		if !tagged {
+			if fv.Kind() == reflect.Struct && fv.Type() != timeType {
+				if err := bindStruct(fv, sources); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+
+		if values == nil {
+			def, ok := sf.Tag.Lookup("default")
+			if !ok {
+				continue
+			}
+			values = []string{def}
+		}
+
+		if err := setFromStrings(fv, values); err != nil {
+			return conversionError(source, name, fv.Type(), err)
+		}
+	}
+
+	return nil
+}
+
+// setFromStrings sets v from values. Slices get one element per value, or per comma separated part of a single value, and
+// other types get the first value.
+func setFromStrings(v reflect.Value, values []string) error {
+	if v.Kind() != reflect.Slice || v.Type().Elem().Kind() == reflect.Uint8 {
+		return setFromString(v, values[0])
+	}
+
+	if len(values) == 1 {
+		values = strings.Split(values[0], ",")
+	}
+
+	s := reflect.MakeSlice(v.Type(), len(values), len(values))
+	for i, value := range values {
+		if err := setFromString(s.Index(i), strings.TrimSpace(value)); err != nil {
+			return err
+		}
+	}
+	v.Set(s)
+
+	return nil
+}
+
+// setFromString converts s to the type of v and sets v.
+func setFromString(v reflect.Value, s string) error {
+	if v.Kind() == reflect.Pointer {
+		p := reflect.New(v.Type().Elem())
+		if err := setFromString(p.Elem(), s); err != nil {
+			return err
+		}
+		v.Set(p)
+		return nil
+	}
+
+	switch v.Type() {
+	case timeType:
+		tm, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			tm, err = time.Parse(time.DateOnly, s)
+		}
+		if err != nil {
+			return err
+		}
+		v.Set(reflect.ValueOf(tm))
+		return nil
+
+	case durationType:
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(d))
+		return nil
+	}
+
+	if v.CanAddr() {
+		if u, ok := v.Addr().Interface().(encoding.TextUnmarshaler); ok {
+			return u.UnmarshalText([]byte(s))
+		}
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(s)
+
+	case reflect.Slice:
+		if v.Type().Elem().Kind() != reflect.Uint8 {
+			return fmt.Errorf("unsupported type %s", v.Type())
+		}
+		v.SetBytes([]byte(s))
+
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(s, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetInt(n)
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(s, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetUint(n)
+
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(s, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetFloat(f)
+
+	default:
+		return fmt.Errorf("unsupported type %s", v.Type())
+	}
+
+	return nil
+}
+
+// conversionError returns the JSONError of the parameter name of src which could not be converted to typ. Its message
+// key is the tag of src followed by "_type", or "_type_list" for slices, such as "query_type".
+func conversionError(src bindSource, name string, typ reflect.Type, err error) *JSONError {
+	for typ.Kind() == reflect.Pointer {
+		typ = typ.Elem()
+	}
+
+	if typ.Kind() == reflect.Slice && typ.Elem().Kind() != reflect.Uint8 {
+		noun := typeNoun(typ.Elem())
+		return &JSONError{
+			Kind:   JSONErrType,
+			Key:    src.tag + "_type_list",
+			Params: map[string]interface{}{"type": noun},
+			Field:  name,
+			Err:    err,
+			msg:    fmt.Sprintf("%s %q must be a list of valid %s values", src.label, name, noun),
+		}
+	}
+
+	noun := typeNoun(typ)
+	return &JSONError{
+		Kind:   JSONErrType,
+		Key:    src.tag + "_type",
+		Params: map[string]interface{}{"type": noun},
+		Field:  name,
+		Err:    err,
+		msg:    fmt.Sprintf("%s %q must be a valid %s", src.label, name, noun),
+	}
+}
+
+// typeNoun names the kind of values held by typ.
+func typeNoun(typ reflect.Type) string {
+	for typ.Kind() == reflect.Pointer {
+		typ = typ.Elem()
+	}
+
+	switch {
+	case typ == timeType:
+		return "time"
+	case typ == durationType:
+		return "duration"
+	}
+
+	switch typ.Kind() {
+	case reflect.Bool:
+		return "boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return "integer"
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "non-negative integer"
+	case reflect.Float32, reflect.Float64:
+		return "number"
+	default:
+		return "value"
+	}
+}
@@ -0,0 +1,169 @@
+package toolkit
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+type listParams struct {
+	Page    int           `query:"page" default:"1"`
+	PerPage uint          `query:"per_page" default:"20"`
+	Sort    string        `query:"sort" default:"name"`
+	Desc    bool          `query:"desc"`
+	Min     *float64      `query:"min"`
+	IDs     []int         `query:"id"`
+	Since   time.Time     `query:"since"`
+	Timeout time.Duration `query:"timeout"`
+	Filter  struct {
+		Tags []string `query:"tag"`
+	}
+	Ignored string
+}
+
+var readQueryTests = []struct {
+	name  string
+	query string
+	field string
+	check func(p listParams) bool
+}{
+	{name: "defaults", query: "", check: func(p listParams) bool {
+		return p.Page == 1 && p.PerPage == 20 && p.Sort == "name" && !p.Desc && p.Min == nil && p.IDs == nil
+	}},
+	{name: "empty value uses default", query: "page=", check: func(p listParams) bool { return p.Page == 1 }},
+	{name: "scalars", query: "page=3&per_page=50&sort=size&desc=true&min=1.5", check: func(p listParams) bool {
+		return p.Page == 3 && p.PerPage == 50 && p.Sort == "size" && p.Desc && p.Min != nil && *p.Min == 1.5
+	}},
+	{name: "repeated slice", query: "id=1&id=2", check: func(p listParams) bool { return reflect.DeepEqual(p.IDs, []int{1, 2}) }},
+	{name: "comma separated slice", query: "id=1,2,3", check: func(p listParams) bool { return reflect.DeepEqual(p.IDs, []int{1, 2, 3}) }},
+	{name: "times", query: "since=2024-01-02&timeout=1m30s", check: func(p listParams) bool {
+		return p.Since.Equal(time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)) && p.Timeout == 90*time.Second
+	}},
+	{name: "rfc 3339 time", query: "since=2024-01-02T03:04:05Z", check: func(p listParams) bool { return p.Since.Hour() == 3 }},
+	{name: "nested struct", query: "tag=a&tag=b", check: func(p listParams) bool { return reflect.DeepEqual(p.Filter.Tags, []string{"a", "b"}) }},
+	{name: "untagged field", query: "Ignored=x", check: func(p listParams) bool { return p.Ignored == "" }},
+	{name: "bad int", query: "page=two", field: "page"},
+	{name: "negative uint", query: "per_page=-1", field: "per_page"},
+	{name: "bad bool", query: "desc=maybe", field: "desc"},
+	{name: "bad slice element", query: "id=1,x", field: "id"},
+	{name: "bad time", query: "since=yesterday", field: "since"},
+}
+
+func TestTools_ReadQuery(t *testing.T) {
+	for _, e := range readQueryTests {
+		var testTools Tools
+
+		var params listParams
+		req, _ := http.NewRequest("GET", "/?"+e.query, nil)
+		err := testTools.ReadQuery(req, &params)
+
+		if e.field != "" {
+			var jsonErr *JSONError
+			if !errors.As(err, &jsonErr) || jsonErr.Kind != JSONErrType || jsonErr.Field != e.field {
+				t.Errorf("%s: expected a type error on %q but got %v", e.name, e.field, err)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("%s: unexpected error %v", e.name, err)
+			continue
+		}
+
+		if !e.check(params) {
+			t.Errorf("%s: wrong values %+v", e.name, params)
+		}
+	}
+}
+
+func TestTools_ReadQueryInvalidTarget(t *testing.T) {
+	var testTools Tools
+
+	var params listParams
+	req, _ := http.NewRequest("GET", "/", nil)
+	err := testTools.ReadQuery(req, params)
+
+	var jsonErr *JSONError
+	if !errors.As(err, &jsonErr) || jsonErr.Kind != JSONErrInvalidTarget {
+		t.Errorf("expected an invalid target error but got %v", err)
+	}
+}
+
+type signupForm struct {
+	Email string `form:"email"`
+	Age   int    `form:"age"`
+}
+
+func TestTools_ReadForm(t *testing.T) {
+	var testTools Tools
+
+	form := url.Values{"email": {"a@example.com"}, "age": {"30"}}
+	req, _ := http.NewRequest("POST", "/", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	var signup signupForm
+	if err := testTools.ReadForm(httptest.NewRecorder(), req, &signup); err != nil {
+		t.Fatal(err)
+	}
+
+	if signup.Email != "a@example.com" || signup.Age != 30 {
+		t.Errorf("wrong values %+v", signup)
+	}
+
+	testTools.MaxJsonSize = 10
+	req, _ = http.NewRequest("POST", "/", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	err := testTools.ReadForm(httptest.NewRecorder(), req, &signup)
+	var jsonErr *JSONError
+	if !errors.As(err, &jsonErr) || jsonErr.Kind != JSONErrTooLarge {
+		t.Errorf("expected a too large error but got %v", err)
+	}
+}
+
+type updateParams struct {
+	ID     int    `path:"id"`
+	Name   string `query:"name" form:"name"`
+	DryRun bool   `query:"dry_run"`
+}
+
+func TestTools_Bind(t *testing.T) {
+	var testTools Tools
+
+	var params updateParams
+	var bindErr error
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /items/{id}", func(w http.ResponseWriter, r *http.Request) {
+		bindErr = testTools.Bind(w, r, &params)
+	})
+
+	req, _ := http.NewRequest("POST", "/items/42?name=query&dry_run=1", strings.NewReader("name=form"))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	mux.ServeHTTP(httptest.NewRecorder(), req)
+
+	if bindErr != nil {
+		t.Fatal(bindErr)
+	}
+
+	if params.ID != 42 || params.Name != "form" || !params.DryRun {
+		t.Errorf("wrong values %+v", params)
+	}
+
+	req, _ = http.NewRequest("POST", "/items/x", nil)
+	mux.ServeHTTP(httptest.NewRecorder(), req)
+
+	var jsonErr *JSONError
+	if !errors.As(bindErr, &jsonErr) || jsonErr.Field != "id" || jsonErr.StatusCode() != http.StatusBadRequest {
+		t.Errorf("expected a type error on id but got %v", bindErr)
+	}
+
+	if bindErr.Error() != `path value "id" must be a valid integer` {
+		t.Errorf("wrong message %q", bindErr.Error())
+	}
+}
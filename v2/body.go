@@ -0,0 +1,231 @@
+package toolkit
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"reflect"
+	"strings"
+)
+
+// DecodeFunc decodes the raw body of a request into data. It is used by ReadBody for the media types it is registered for.
+type DecodeFunc func(body []byte, data interface{}) error
+
+// RegisterCodec registers decode for request bodies whose Content-Type is mediaType, such as application/cbor, replacing
+// the codec built into ReadBody for that media type, if any.
+func (t *Tools) RegisterCodec(mediaType string, decode DecodeFunc) {
+	if t.codecs == nil {
+		t.codecs = make(map[string]DecodeFunc)
+	}
+	t.codecs[strings.ToLower(mediaType)] = decode
+}
+
+// ReadBody reads the body of r into data, picking the decoder from the Content-Type of the request:
+//
+//   - JSON, including application/*+json, and requests without a Content-Type are read by ReadJSON;
+//   - NDJSON (application/x-ndjson or application/jsonl) is read into data, which must point to a slice, one element per
+//     line, each following the rules of ReadJSON;
+//   - XML (application/xml, text/xml and application/*+xml) is read with encoding/xml;
+//   - URL-encoded and multipart forms are read by ReadForm;
+//   - any media type registered with RegisterCodec is read by its codec.
+//
+// Bodies are limited to MaxJsonSize bytes, or 1 MB, and compressed bodies are decompressed as for ReadJSON. When
+// t.ValidateJSON is set, the decoded data is checked against its validate struct tags whatever its format. Other media
+// types are refused with a 415 JSONError.
+func (t *Tools) ReadBody(w http.ResponseWriter, r *http.Request, data interface{}) error {
+	maxBytes := t.maxJSONBytes()
+
+	contentType := r.Header.Get("Content-Type")
+	if contentType == "" {
+		return t.ReadJSON(w, r, data)
+	}
+
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return &JSONError{Kind: JSONErrUnsupportedMediaType, Key: "invalid_content_type", Err: err, msg: "Content-Type header is not valid"}
+	}
+
+	if decode, ok := t.codecs[mediaType]; ok {
+		raw, err := t.readRawBody(w, r, maxBytes)
+		if err != nil {
+			return err
+		}
+
+		if err = decode(raw, data); err != nil {
+			var jsonErr *JSONError
+			if errors.As(err, &jsonErr) {
+				return err
+			}
+			return &JSONError{
+				Kind:   JSONErrSyntax,
+				Key:    "bad_body",
+				Params: map[string]interface{}{"media_type": mediaType},
+				Err:    err,
+				msg:    fmt.Sprintf("body contains badly-formed %s", mediaType),
+			}
+		}
+
+		return t.validateBody(data)
+	}
+
+	switch {
+	case mediaType == "application/x-ndjson" || mediaType == "application/jsonl":
+		return t.readNDJSONBody(w, r, data, maxBytes)
+
+	case isJSONMediaType(mediaType):
+		return t.ReadJSON(w, r, data)
+
+	case isXMLMediaType(mediaType):
+		raw, err := t.readRawBody(w, r, maxBytes)
+		if err != nil {
+			return err
+		}
+
+		if err = decodeXML(raw, data); err != nil {
+			return err
+		}
+
+		return t.validateBody(data)
+
+	case mediaType == "application/x-www-form-urlencoded" || mediaType == "multipart/form-data":
+		if err := t.ReadForm(w, r, data); err != nil {
+			return err
+		}
+
+		return t.validateBody(data)
+
+	default:
+		return &JSONError{
+			Kind:   JSONErrUnsupportedMediaType,
+			Key:    "unsupported_content_type",
+			Params: map[string]interface{}{"media_type": mediaType},
+			msg:    fmt.Sprintf("Content-Type %s is not supported", mediaType),
+		}
+	}
+}
+
+// readRawBody reads the whole body of r, limited to maxBytes and decompressed, without looking at its content.
+func (t *Tools) readRawBody(w http.ResponseWriter, r *http.Request, maxBytes int) ([]byte, error) {
+	r.Body = http.MaxBytesReader(w, r.Body, int64(maxBytes))
+
+	body, err := decompressBody(r.Body, r.Header.Get("Content-Encoding"), int64(maxBytes))
+	if err != nil {
+		return nil, err
+	}
+
+	raw, err := io.ReadAll(body)
+	if err != nil {
+		if jsonErr := newJSONError(err, maxBytes, raw); jsonErr != nil {
+			return nil, jsonErr
+		}
+		return nil, err
+	}
+
+	if len(raw) == 0 {
+		return nil, &JSONError{Kind: JSONErrEmpty, Key: "empty", msg: "body must not be empty"}
+	}
+
+	return raw, nil
+}
+
+// readNDJSONBody appends every line of the NDJSON body of r to the slice data points to.
+func (t *Tools) readNDJSONBody(w http.ResponseWriter, r *http.Request, data interface{}, maxBytes int) error {
+	v := reflect.ValueOf(data)
+	if v.Kind() != reflect.Pointer || v.IsNil() || v.Elem().Kind() != reflect.Slice {
+		return &JSONError{Kind: JSONErrInvalidTarget, msg: "NDJSON body must be read into a pointer to a slice"}
+	}
+	slice := v.Elem()
+
+	r.Body = http.MaxBytesReader(w, r.Body, int64(maxBytes))
+
+	body, err := t.jsonBody(r, int64(maxBytes), isJSONStreamMediaType)
+	if err != nil {
+		return err
+	}
+
+	err = readNDJSON(bufio.NewReader(body), maxBytes, func(index int, raw []byte, err error) error {
+		if err == nil {
+			elem := reflect.New(slice.Type().Elem())
+			if err = t.decodeJSON(raw, elem.Interface(), maxBytes); err == nil {
+				slice.Set(reflect.Append(slice, elem.Elem()))
+				return nil
+			}
+		}
+		return &ElementError{Index: index, Err: err}
+	})
+	if err != nil {
+		var elementErr *ElementError
+		if errors.As(err, &elementErr) {
+			return err
+		}
+		return streamError(err, maxBytes)
+	}
+
+	return nil
+}
+
+// decodeXML decodes the XML document raw into data, converting its errors into JSONError.
+func decodeXML(raw []byte, data interface{}) error {
+	dec := xml.NewDecoder(bytes.NewReader(raw))
+	dec.CharsetReader = func(charset string, input io.Reader) (io.Reader, error) {
+		switch strings.ToLower(charset) {
+		case "utf-8", "utf8", "us-ascii":
+			return input, nil
+		case "iso-8859-1", "latin1":
+			return &latin1Reader{r: input}, nil
+		default:
+			return nil, unsupportedCharset(charset)
+		}
+	}
+
+	err := dec.Decode(data)
+	if err == nil {
+		return nil
+	}
+
+	var jsonErr *JSONError
+	var syntaxError *xml.SyntaxError
+
+	switch {
+	case errors.As(err, &jsonErr):
+		return jsonErr
+	case errors.As(err, &syntaxError):
+		return &JSONError{
+			Kind: JSONErrSyntax,
+			Key:  "xml_syntax",
+			Line: syntaxError.Line,
+			Err:  err,
+			msg:  fmt.Sprintf("body contains badly-formed XML (at line %d)", syntaxError.Line),
+		}
+	case errors.Is(err, io.EOF):
+		return &JSONError{Kind: JSONErrEmpty, Key: "empty", Err: err, msg: "body must not be empty"}
+	default:
+		return &JSONError{
+			Kind:   JSONErrType,
+			Key:    "xml_type",
+			Params: map[string]interface{}{"error": err.Error()},
+			Err:    err,
+			msg:    fmt.Sprintf("body contains incorrect XML: %s", err),
+		}
+	}
+}
+
+// validateBody checks data against its validate struct tags when t.ValidateJSON is set.
+func (t *Tools) validateBody(data interface{}) error {
+	if t.ValidateJSON {
+		return t.Validate(data)
+	}
+
+	return nil
+}
+
+// isXMLMediaType reports whether mediaType is XML.
+func isXMLMediaType(mediaType string) bool {
+	return mediaType == "application/xml" || mediaType == "text/xml" ||
+		(strings.HasPrefix(mediaType, "application/") && strings.HasSuffix(mediaType, "+xml"))
+}
@@ -0,0 +1,127 @@
+package toolkit
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+type bodyItem struct {
+	Name string `json:"name" xml:"name" form:"name" validate:"required"`
+	Qty  int    `json:"qty" xml:"qty" form:"qty"`
+}
+
+// decodeKeyValue is a test codec reading name=qty bodies.
+func decodeKeyValue(body []byte, data interface{}) error {
+	name, qty, ok := strings.Cut(string(body), "=")
+	if !ok {
+		return errors.New("missing =")
+	}
+
+	n, err := strconv.Atoi(qty)
+	if err != nil {
+		return err
+	}
+
+	item := data.(*bodyItem)
+	item.Name, item.Qty = name, n
+
+	return nil
+}
+
+var readBodyTests = []struct {
+	name        string
+	contentType string
+	body        string
+	want        bodyItem
+	kind        JSONErrorKind
+}{
+	{name: "json", contentType: "application/json", body: `{"name": "pen", "qty": 2}`, want: bodyItem{Name: "pen", Qty: 2}},
+	{name: "json suffix", contentType: "application/vnd.item+json", body: `{"name": "pen", "qty": 2}`, want: bodyItem{Name: "pen", Qty: 2}},
+	{name: "no content type", body: `{"name": "pen", "qty": 2}`, want: bodyItem{Name: "pen", Qty: 2}},
+	{name: "xml", contentType: "application/xml", body: `<item><name>pen</name><qty>2</qty></item>`, want: bodyItem{Name: "pen", Qty: 2}},
+	{name: "text xml", contentType: "text/xml; charset=utf-8", body: `<?xml version="1.0" encoding="ISO-8859-1"?><item><name>caf` + "\xe9" + `</name></item>`, want: bodyItem{Name: "café"}},
+	{name: "form", contentType: "application/x-www-form-urlencoded", body: "name=pen&qty=2", want: bodyItem{Name: "pen", Qty: 2}},
+	{name: "registered codec", contentType: "text/x-key-value", body: "pen=2", want: bodyItem{Name: "pen", Qty: 2}},
+	{name: "bad xml", contentType: "application/xml", body: `<item><name>pen</item>`, kind: JSONErrSyntax},
+	{name: "wrong xml type", contentType: "application/xml", body: `<item><qty>two</qty></item>`, kind: JSONErrType},
+	{name: "empty xml", contentType: "application/xml", body: ``, kind: JSONErrEmpty},
+	{name: "bad codec body", contentType: "text/x-key-value", body: "pen", kind: JSONErrSyntax},
+	{name: "invalid fields", contentType: "application/xml", body: `<item><qty>2</qty></item>`, kind: "validation"},
+	{name: "unsupported", contentType: "text/csv", body: "pen,2", kind: JSONErrUnsupportedMediaType},
+}
+
+func TestTools_ReadBody(t *testing.T) {
+	for _, e := range readBodyTests {
+		var testTools Tools
+		testTools.ValidateJSON = true
+		testTools.RegisterCodec("text/x-key-value", decodeKeyValue)
+
+		var item bodyItem
+		req, _ := http.NewRequest("POST", "/", strings.NewReader(e.body))
+		if e.contentType != "" {
+			req.Header.Set("Content-Type", e.contentType)
+		}
+
+		err := testTools.ReadBody(httptest.NewRecorder(), req, &item)
+
+		switch e.kind {
+		case "":
+			if err != nil {
+				t.Errorf("%s: unexpected error %v", e.name, err)
+			} else if item != e.want {
+				t.Errorf("%s: expected %+v but got %+v", e.name, e.want, item)
+			}
+
+		case "validation":
+			var errs ValidationErrors
+			if !errors.As(err, &errs) {
+				t.Errorf("%s: expected validation errors but got %v", e.name, err)
+			}
+
+		default:
+			var jsonErr *JSONError
+			if !errors.As(err, &jsonErr) || jsonErr.Kind != e.kind {
+				t.Errorf("%s: expected a %s error but got %v", e.name, e.kind, err)
+			}
+		}
+	}
+}
+
+func TestTools_ReadBodyNDJSON(t *testing.T) {
+	var testTools Tools
+
+	var items []bodyItem
+	req, _ := http.NewRequest("POST", "/", strings.NewReader("{\"name\": \"a\"}\n\n{\"name\": \"b\", \"qty\": 1}\n"))
+	req.Header.Set("Content-Type", "application/x-ndjson")
+
+	if err := testTools.ReadBody(httptest.NewRecorder(), req, &items); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(items) != 2 || items[0].Name != "a" || items[1].Qty != 1 {
+		t.Errorf("wrong items %+v", items)
+	}
+
+	req, _ = http.NewRequest("POST", "/", strings.NewReader("{\"name\": \"a\"}\n{\"name\": 1}\n"))
+	req.Header.Set("Content-Type", "application/x-ndjson")
+
+	var elementErr *ElementError
+	err := testTools.ReadBody(httptest.NewRecorder(), req, &items)
+	if !errors.As(err, &elementErr) || elementErr.Index != 1 {
+		t.Errorf("expected an error on element 1 but got %v", err)
+	}
+
+	var item bodyItem
+	req, _ = http.NewRequest("POST", "/", strings.NewReader("{}\n"))
+	req.Header.Set("Content-Type", "application/x-ndjson")
+
+	var jsonErr *JSONError
+	err = testTools.ReadBody(httptest.NewRecorder(), req, &item)
+	if !errors.As(err, &jsonErr) || jsonErr.Kind != JSONErrInvalidTarget {
+		t.Errorf("expected an invalid target error but got %v", err)
+	}
+}
@@ -0,0 +1,142 @@
+package toolkit
+
+import (
+	"io"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// precompressedVariants are the encodings of the siblings looked for by servePrecompressed, in order of preference.
+var precompressedVariants = []struct {
+	encoding string
+	suffix   string
+}{
+	{encoding: "br", suffix: ".br"},
+	{encoding: "gzip", suffix: ".gz"},
+}
+
+// servePrecompressed serves the first precompressed sibling of dc accepted by the client, and reports whether it did.
+func (t *Tools) servePrecompressed(w http.ResponseWriter, r *http.Request, dc downloadContent) bool {
+	for _, v := range precompressedVariants {
+		if !acceptsEncoding(r, v.encoding) {
+			continue
+		}
+
+		f, err := dc.sibling(v.suffix)
+		if err != nil {
+			continue
+		}
+		defer f.Close()
+
+		info, err := f.Stat()
+		if err != nil || info.IsDir() {
+			continue
+		}
+
+		content, ok := f.(io.ReadSeeker)
+		if !ok {
+			continue
+		}
+
+		//	ranges apply to the encoded bytes of the sibling; http.ServeContent leaves out the Content-Length once
+		//	Content-Encoding is set, which keeps partial responses from claiming the size of the whole sibling
+		w.Header().Set("Content-Encoding", v.encoding)
+		http.ServeContent(w, r, dc.name, info.ModTime(), content)
+
+		return true
+	}
+
+	return false
+}
+
+// setContentType sets the Content-Type of the response from the extension of name, or by sniffing content, unless it is
+// already set. The content is rewound afterwards.
+func setContentType(w http.ResponseWriter, name string, content io.ReadSeeker) error {
+	if w.Header().Get("Content-Type") != "" {
+		return nil
+	}
+
+	ctype := mime.TypeByExtension(filepath.Ext(name))
+	if ctype == "" {
+		buff := make([]byte, 512)
+		n, err := io.ReadFull(content, buff)
+		if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+			return err
+		}
+		ctype = http.DetectContentType(buff[:n])
+
+		if _, err = content.Seek(0, io.SeekStart); err != nil {
+			return err
+		}
+	}
+
+	w.Header().Set("Content-Type", ctype)
+
+	return nil
+}
+
+// acceptsEncoding reports whether the Accept-Encoding header of r allows the given content coding.
+func acceptsEncoding(r *http.Request, encoding string) bool {
+	accepted := false
+
+	for _, header := range r.Header.Values("Accept-Encoding") {
+		for _, part := range strings.Split(header, ",") {
+			coding, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+			coding = strings.TrimSpace(coding)
+			if !strings.EqualFold(coding, encoding) && coding != "*" {
+				continue
+			}
+
+			q := 1.0
+			if k, v, ok := strings.Cut(strings.TrimSpace(params), "="); ok && strings.TrimSpace(k) == "q" {
+				if f, err := strconv.ParseFloat(strings.TrimSpace(v), 64); err == nil {
+					q = f
+				}
+			}
+
+			//	an explicit entry for the coding wins over the wildcard
+			if coding != "*" {
+				return q > 0
+			}
+			accepted = q > 0
+		}
+	}
+
+	return accepted
+}
+
+// isConditional reports whether r is a conditional or Range request, which http.ServeContent has to answer.
+func isConditional(r *http.Request) bool {
+	for _, header := range []string{"Range", "If-Range", "If-Match", "If-None-Match", "If-Modified-Since", "If-Unmodified-Since"} {
+		if r.Header.Get(header) != "" {
+			return true
+		}
+	}
+
+	return false
+}
+
+// isCompressible reports whether content of the given type is worth compressing.
+func isCompressible(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+
+	switch {
+	case strings.HasPrefix(mediaType, "text/"),
+		strings.HasSuffix(mediaType, "+json"),
+		strings.HasSuffix(mediaType, "+xml"):
+		return true
+	}
+
+	switch mediaType {
+	case "application/json", "application/xml", "application/javascript", "application/x-ndjson", "image/svg+xml":
+		return true
+	}
+
+	return false
+}
@@ -0,0 +1,167 @@
+package toolkit
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+var precompressedTests = []struct {
+	name           string
+	acceptEncoding string
+	encoding       string
+	body           string
+}{
+	{name: "brotli", acceptEncoding: "gzip, deflate, br", encoding: "br", body: "brotli bytes"},
+	{name: "gzip", acceptEncoding: "gzip", encoding: "gzip", body: "gzip bytes"},
+	{name: "brotli refused", acceptEncoding: "br;q=0, *", encoding: "gzip", body: "gzip bytes"},
+	{name: "identity", acceptEncoding: "", encoding: "", body: `{"foo": "bar"}`},
+}
+
+func TestTools_DownloadPrecompressed(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{"data.json": `{"foo": "bar"}`, "data.json.gz": "gzip bytes", "data.json.br": "brotli bytes"}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var testTools Tools
+	testTools.ServePrecompressed = true
+
+	for _, e := range precompressedTests {
+		rr := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/", nil)
+		if e.acceptEncoding != "" {
+			req.Header.Set("Accept-Encoding", e.acceptEncoding)
+		}
+
+		testTools.DownloadFile(rr, req, dir, "data.json", "data.json")
+
+		if rr.Header().Get("Content-Encoding") != e.encoding {
+			t.Errorf("%s: expected encoding %q but got %q", e.name, e.encoding, rr.Header().Get("Content-Encoding"))
+		}
+
+		if rr.Body.String() != e.body {
+			t.Errorf("%s: wrong body %q", e.name, rr.Body.String())
+		}
+
+		if rr.Header().Get("Content-Type") != "application/json" {
+			t.Errorf("%s: wrong content type %s", e.name, rr.Header().Get("Content-Type"))
+		}
+
+		if rr.Header().Get("Vary") != "Accept-Encoding" {
+			t.Errorf("%s: missing Vary header", e.name)
+		}
+	}
+
+	rr := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	req.Header.Set("Range", "bytes=0-3")
+
+	testTools.DownloadFile(rr, req, dir, "data.json", "data.json")
+
+	if rr.Code != http.StatusPartialContent || rr.Body.String() != "gzip" {
+		t.Errorf("wrong range of the gzip variant %d %q", rr.Code, rr.Body.String())
+	}
+
+	if cl := rr.Header().Get("Content-Length"); cl != "" && cl != "4" {
+		t.Errorf("partial response claims a Content-Length of %s", cl)
+	}
+}
+
+func TestTools_DownloadCompressOnTheFly(t *testing.T) {
+	var testTools Tools
+	testTools.CompressDownloads = true
+
+	csv := strings.Repeat("a,b,c\n", 100)
+
+	rr := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+
+	if err := testTools.DownloadReader(rr, req, strings.NewReader(csv), -1, "export.csv"); err != nil {
+		t.Fatal(err)
+	}
+
+	if rr.Header().Get("Content-Encoding") != "gzip" {
+		t.Fatalf("expected gzip encoding but got %q", rr.Header().Get("Content-Encoding"))
+	}
+
+	gr, err := gzip.NewReader(rr.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(gr)
+	if string(body) != csv {
+		t.Error("decompressed body does not match")
+	}
+
+	rr = httptest.NewRecorder()
+	req.Header.Set("Accept-Encoding", "gzip")
+	testTools.DownloadStaticFile(rr, req, "./testdata/pic.jpg", "rowdy-cat.jpg")
+
+	if rr.Header().Get("Content-Encoding") != "" || rr.Body.Len() != 91585 {
+		t.Error("images should not be compressed")
+	}
+}
+
+var compressConditionalTests = []struct {
+	name   string
+	header string
+	value  string
+	status int
+	body   string
+}{
+	{name: "range", header: "Range", value: "bytes=0-4", status: http.StatusPartialContent, body: "a,b,c"},
+	{name: "not modified", header: "If-Modified-Since", value: "Mon, 02 Jan 2006 15:04:05 GMT", status: http.StatusNotModified},
+}
+
+func TestTools_DownloadCompressConditional(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "export.csv"), []byte(strings.Repeat("a,b,c\n", 100)), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	modtime := time.Date(2006, time.January, 2, 15, 4, 5, 0, time.UTC)
+	if err := os.Chtimes(filepath.Join(dir, "export.csv"), modtime, modtime); err != nil {
+		t.Fatal(err)
+	}
+
+	var testTools Tools
+	testTools.CompressDownloads = true
+
+	rr := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	testTools.DownloadFile(rr, req, dir, "export.csv", "export.csv")
+
+	if rr.Header().Get("Content-Encoding") != "gzip" || rr.Header().Get("Last-Modified") != modtime.Format(http.TimeFormat) {
+		t.Errorf("expected a gzipped response with Last-Modified but got %v", rr.Header())
+	}
+
+	for _, e := range compressConditionalTests {
+		rr := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/", nil)
+		req.Header.Set("Accept-Encoding", "gzip")
+		req.Header.Set(e.header, e.value)
+
+		testTools.DownloadFile(rr, req, dir, "export.csv", "export.csv")
+
+		if rr.Code != e.status || rr.Body.String() != e.body {
+			t.Errorf("%s: expected %d %q but got %d %q", e.name, e.status, e.body, rr.Code, rr.Body.String())
+		}
+
+		if rr.Header().Get("Content-Encoding") != "" {
+			t.Errorf("%s: conditional response should not be compressed", e.name)
+		}
+	}
+}
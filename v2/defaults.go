@@ -0,0 +1,233 @@
+package toolkit
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// defaultsCache remembers whether a type holds any default tag, keyed by reflect.Type.
+var defaultsCache sync.Map
+
+// FieldSet holds the JSON paths of the fields present in a body, such as "address", "address.city" and "items[2].qty".
+// Fields sent as null are present.
+type FieldSet map[string]bool
+
+// Has reports whether the field at path was present in the body.
+func (f FieldSet) Has(path string) bool {
+	return f[path]
+}
+
+// ReadJSONFields reads the body of r into data as ReadJSON does, and returns the set of fields the body held, so fields
+// which were left out can be told apart from fields explicitly set to their zero value. Fields are named after their JSON
+// names, and keys which match no field of data are left out.
+func (t *Tools) ReadJSONFields(w http.ResponseWriter, r *http.Request, data interface{}) (FieldSet, error) {
+	maxBytes := t.maxJSONBytes()
+
+	raw, err := t.readJSONBody(w, r, maxBytes)
+	if err != nil {
+		return nil, err
+	}
+
+	doc, err := t.decodeJSONDocument(raw, data, maxBytes, true, true)
+	if err != nil {
+		return nil, err
+	}
+
+	fields := make(FieldSet)
+	collectFields(doc, reflect.TypeOf(data), "", fields)
+
+	return fields, nil
+}
+
+// collectFields adds the path of every member of the JSON value v to fields, naming them after the fields of typ, which
+// may be nil when unknown.
+func collectFields(v interface{}, typ reflect.Type, p string, fields FieldSet) {
+	if typ != nil {
+		typ = indirectType(typ)
+	}
+
+	switch x := v.(type) {
+	case map[string]interface{}:
+		for key, value := range x {
+			name, childType := key, reflect.Type(nil)
+
+			if typ != nil && typ.Kind() == reflect.Struct {
+				sf, fieldName, ok := structFieldByJSONName(typ, key)
+				if !ok {
+					continue
+				}
+				name, childType = fieldName, sf.Type
+			} else if typ != nil && typ.Kind() == reflect.Map {
+				childType = typ.Elem()
+			}
+
+			path := joinPath(p, name)
+			fields[path] = true
+			collectFields(value, childType, path, fields)
+		}
+
+	case []interface{}:
+		var childType reflect.Type
+		if typ != nil && (typ.Kind() == reflect.Slice || typ.Kind() == reflect.Array) {
+			childType = typ.Elem()
+		}
+
+		for i, item := range x {
+			path := fmt.Sprintf("%s[%d]", p, i)
+			fields[path] = true
+			collectFields(item, childType, path, fields)
+		}
+	}
+}
+
+// applyDefaults sets the fields of data which were missing from the decoded JSON document doc from their default tag, as
+// in `json:"per_page" default:"20"`. Defaults are applied inside nested structs, and inside the elements of slices and
+// maps, using the conversions of Bind. Fields present in doc, even as null, and fields data already holds a value for are
+// left untouched.
+func applyDefaults(doc, data interface{}) error {
+	v := reflect.ValueOf(data)
+	if v.Kind() != reflect.Pointer || v.IsNil() || !hasDefaults(v.Type(), nil) {
+		return nil
+	}
+
+	return setDefaults(v, doc, "")
+}
+
+// setDefaults applies the default tags of v, whose JSON value is doc, or nil when it was missing.
+func setDefaults(v reflect.Value, doc interface{}, p string) error {
+	switch v.Kind() {
+	case reflect.Pointer:
+		if v.IsNil() {
+			return nil
+		}
+		return setDefaults(v.Elem(), doc, p)
+
+	case reflect.Interface:
+		//	values held directly by an interface can not be set
+		if v.IsNil() || v.Elem().Kind() != reflect.Pointer {
+			return nil
+		}
+		return setDefaults(v.Elem(), doc, p)
+
+	case reflect.Struct:
+		if v.Type() == timeType {
+			return nil
+		}
+
+		obj, _ := doc.(map[string]interface{})
+		for _, sf := range reflect.VisibleFields(v.Type()) {
+			if !sf.IsExported() || (sf.Anonymous && sf.Tag.Get("json") == "" && indirectType(sf.Type).Kind() == reflect.Struct) {
+				continue
+			}
+
+			name, ok := jsonFieldName(sf)
+			if !ok {
+				continue
+			}
+
+			fv, err := v.FieldByIndexErr(sf.Index)
+			if err != nil {
+				//	the field is promoted through a nil embedded pointer
+				continue
+			}
+
+			value, present := jsonMember(obj, name)
+			path := joinPath(p, name)
+
+			//	a value already held, such as one kept by a patch, wins over the default
+			if def, ok := sf.Tag.Lookup("default"); ok && !present && fv.IsZero() {
+				if err := setFromStrings(fv, []string{def}); err != nil {
+					return &JSONError{Kind: JSONErrInvalidTarget, Field: path, Err: err, msg: fmt.Sprintf("default value of field %q is not valid", path)}
+				}
+			}
+
+			if err := setDefaults(fv, value, path); err != nil {
+				return err
+			}
+		}
+
+	case reflect.Slice, reflect.Array:
+		arr, _ := doc.([]interface{})
+		for i := 0; i < v.Len(); i++ {
+			var item interface{}
+			if i < len(arr) {
+				item = arr[i]
+			}
+			if err := setDefaults(v.Index(i), item, fmt.Sprintf("%s[%d]", p, i)); err != nil {
+				return err
+			}
+		}
+
+	case reflect.Map:
+		obj, _ := doc.(map[string]interface{})
+		iter := v.MapRange()
+		for iter.Next() {
+			//	map values can not be set in place, so defaults are applied to a copy which is stored back
+			value := reflect.New(v.Type().Elem()).Elem()
+			value.Set(iter.Value())
+
+			key := fmt.Sprint(iter.Key().Interface())
+			if err := setDefaults(value, obj[key], joinPath(p, key)); err != nil {
+				return err
+			}
+			v.SetMapIndex(iter.Key(), value)
+		}
+	}
+
+	return nil
+}
+
+// jsonMember returns the member of obj matching the field name, the way encoding/json matches them: exactly, or else
+// regardless of case.
+func jsonMember(obj map[string]interface{}, name string) (interface{}, bool) {
+	if value, ok := obj[name]; ok {
+		return value, true
+	}
+
+	for key, value := range obj {
+		if strings.EqualFold(key, name) {
+			return value, true
+		}
+	}
+
+	return nil, false
+}
+
+// hasDefaults reports whether typ, or any type it holds, has a field with a default tag.
+func hasDefaults(typ reflect.Type, seen map[reflect.Type]bool) bool {
+	if cached, ok := defaultsCache.Load(typ); ok {
+		return cached.(bool)
+	}
+	if seen[typ] {
+		return false
+	}
+
+	//	only the outermost call knows the whole answer for recursive types, so it alone fills the cache
+	top := seen == nil
+	if top {
+		seen = make(map[reflect.Type]bool)
+	}
+	seen[typ] = true
+
+	found := false
+	switch typ.Kind() {
+	case reflect.Pointer, reflect.Slice, reflect.Array, reflect.Map:
+		found = hasDefaults(typ.Elem(), seen)
+
+	case reflect.Struct:
+		for i := 0; i < typ.NumField() && !found; i++ {
+			sf := typ.Field(i)
+			_, tagged := sf.Tag.Lookup("default")
+			found = tagged || hasDefaults(sf.Type, seen)
+		}
+	}
+
+	if top {
+		defaultsCache.Store(typ, found)
+	}
+
+	return found
+}
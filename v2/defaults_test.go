@@ -0,0 +1,134 @@
+package toolkit
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+type defaultsLine struct {
+	SKU string `json:"sku"`
+	Qty int    `json:"qty" default:"1"`
+}
+
+type defaultsOrder struct {
+	Currency string        `json:"currency" default:"EUR"`
+	Priority int           `json:"priority" default:"5"`
+	Gift     bool          `json:"gift" default:"true"`
+	Tags     []string      `json:"tags" default:"new,web"`
+	Timeout  time.Duration `json:"timeout" default:"30s"`
+	Note     *string       `json:"note" default:"none"`
+	Address  struct {
+		Country string `json:"country" default:"DE"`
+		City    string `json:"city"`
+	} `json:"address"`
+	Billing *struct {
+		Country string `json:"country" default:"DE"`
+	} `json:"billing"`
+	Lines  []defaultsLine          `json:"lines"`
+	Extras map[string]defaultsLine `json:"extras"`
+}
+
+var defaultsTests = []struct {
+	name  string
+	json  string
+	check func(o defaultsOrder) bool
+}{
+	{name: "all missing", json: `{}`, check: func(o defaultsOrder) bool {
+		return o.Currency == "EUR" && o.Priority == 5 && o.Gift && reflect.DeepEqual(o.Tags, []string{"new", "web"}) &&
+			o.Timeout == 30*time.Second && o.Note != nil && *o.Note == "none" && o.Address.Country == "DE" && o.Billing == nil
+	}},
+	{name: "explicit zero values", json: `{"currency": "", "priority": 0, "gift": false, "tags": []}`, check: func(o defaultsOrder) bool {
+		return o.Currency == "" && o.Priority == 0 && !o.Gift && len(o.Tags) == 0
+	}},
+	{name: "explicit null", json: `{"note": null}`, check: func(o defaultsOrder) bool { return o.Note == nil }},
+	{name: "case-insensitive key", json: `{"Currency": "USD"}`, check: func(o defaultsOrder) bool { return o.Currency == "USD" }},
+	{name: "nested struct", json: `{"address": {"city": "Berlin"}, "billing": {}}`, check: func(o defaultsOrder) bool {
+		return o.Address.Country == "DE" && o.Address.City == "Berlin" && o.Billing != nil && o.Billing.Country == "DE"
+	}},
+	{name: "slice elements", json: `{"lines": [{"sku": "a"}, {"sku": "b", "qty": 0}, {"sku": "c", "qty": 3}]}`, check: func(o defaultsOrder) bool {
+		return o.Lines[0].Qty == 1 && o.Lines[1].Qty == 0 && o.Lines[2].Qty == 3
+	}},
+	{name: "map values", json: `{"extras": {"wrap": {"sku": "w"}}}`, check: func(o defaultsOrder) bool { return o.Extras["wrap"].Qty == 1 }},
+}
+
+func TestTools_ReadJSONDefaults(t *testing.T) {
+	for _, e := range defaultsTests {
+		var testTools Tools
+
+		var order defaultsOrder
+		req, _ := http.NewRequest("POST", "/", strings.NewReader(e.json))
+		if err := testTools.ReadJSON(httptest.NewRecorder(), req, &order); err != nil {
+			t.Errorf("%s: unexpected error %v", e.name, err)
+			continue
+		}
+
+		if !e.check(order) {
+			t.Errorf("%s: wrong values %+v", e.name, order)
+		}
+	}
+}
+
+func TestTools_ReadJSONDefaultsPrefilled(t *testing.T) {
+	var testTools Tools
+
+	page := struct {
+		Page    int `json:"page" default:"1"`
+		PerPage int `json:"per_page" default:"20"`
+	}{PerPage: 50}
+
+	req, _ := http.NewRequest("POST", "/", strings.NewReader(`{}`))
+	if err := testTools.ReadJSON(httptest.NewRecorder(), req, &page); err != nil {
+		t.Fatal(err)
+	}
+
+	if page.Page != 1 || page.PerPage != 50 {
+		t.Errorf("defaults overwrote a value already set: %+v", page)
+	}
+}
+
+func TestTools_ReadJSONInvalidDefault(t *testing.T) {
+	var testTools Tools
+
+	var decoded struct {
+		Limit int `json:"limit" default:"ten"`
+	}
+	req, _ := http.NewRequest("POST", "/", strings.NewReader(`{}`))
+	err := testTools.ReadJSON(httptest.NewRecorder(), req, &decoded)
+
+	var jsonErr *JSONError
+	if !errors.As(err, &jsonErr) || jsonErr.Kind != JSONErrInvalidTarget || jsonErr.Field != "limit" {
+		t.Errorf("expected an invalid target error on limit but got %v", err)
+	}
+}
+
+func TestTools_ReadJSONFields(t *testing.T) {
+	var testTools Tools
+
+	var order defaultsOrder
+	req, _ := http.NewRequest("POST", "/", strings.NewReader(`{"Priority": 0, "note": null, "address": {"city": "Berlin"}, "lines": [{"sku": "a"}], "extras": {"wrap": {}}}`))
+	fields, err := testTools.ReadJSONFields(httptest.NewRecorder(), req, &order)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, path := range []string{"priority", "note", "address", "address.city", "lines", "lines[0]", "lines[0].sku", "extras", "extras.wrap"} {
+		if !fields.Has(path) {
+			t.Errorf("expected %s to be present", path)
+		}
+	}
+
+	for _, path := range []string{"currency", "address.country", "lines[0].qty", "billing", "Priority"} {
+		if fields.Has(path) {
+			t.Errorf("expected %s to be missing", path)
+		}
+	}
+
+	if order.Currency != "EUR" || order.Lines[0].Qty != 1 {
+		t.Errorf("defaults not applied: %+v", order)
+	}
+}
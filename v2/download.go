@@ -0,0 +1,431 @@
+package toolkit
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// DownloadOptions holds the optional settings of the download helpers.
+type DownloadOptions struct {
+	// Inline asks the browser to display the file instead of saving it.
+	Inline bool
+	// ContentType overrides the content type detected from the file name and contents.
+	ContentType string
+	// ModTime is the modification time reported by DownloadReader for seekable content. It is ignored when zero.
+	ModTime time.Time
+}
+
+// ErrPathEscapesRoot is returned when a requested name resolves to a location outside of its base directory.
+var ErrPathEscapesRoot = errors.New("path escapes the base directory")
+
+// ResolvePath returns the path of name inside the base directory. Names which are absolute, contain ".." elements or
+// reach outside of base through a symbolic link are rejected with ErrPathEscapesRoot.
+func (t *Tools) ResolvePath(base, name string) (string, error) {
+	name = filepath.FromSlash(name)
+	if strings.ContainsRune(name, 0) || !filepath.IsLocal(name) {
+		return "", ErrPathEscapesRoot
+	}
+
+	root, err := filepath.EvalSymlinks(base)
+	if err != nil {
+		return "", err
+	}
+	root, err = filepath.Abs(root)
+	if err != nil {
+		return "", err
+	}
+
+	resolved, err := filepath.EvalSymlinks(filepath.Join(root, name))
+	if err != nil {
+		return "", err
+	}
+	resolved, err = filepath.Abs(resolved)
+	if err != nil {
+		return "", err
+	}
+
+	rel, err := filepath.Rel(root, resolved)
+	if err != nil || !filepath.IsLocal(rel) {
+		return "", ErrPathEscapesRoot
+	}
+
+	return resolved, nil
+}
+
+// DownloadFile lets the user download the file name stored inside baseDir, which makes it safe to use with names taken
+// from the request. A JSON 403 is sent for names escaping baseDir and a JSON 404 for files which do not exist, and for
+// the sidecar and manifest files written by the toolkit, which are never served.
+func (t *Tools) DownloadFile(w http.ResponseWriter, r *http.Request, baseDir, name, displayName string, opts ...DownloadOptions) {
+	if isMetadataPath(name) {
+		t.downloadError(w, os.ErrNotExist)
+		return
+	}
+
+	f, fp, err := t.openInBase(baseDir, name)
+	if err != nil {
+		t.downloadError(w, err)
+		return
+	}
+	defer f.Close()
+
+	if isMetadataPath(fp) {
+		t.downloadError(w, os.ErrNotExist)
+		return
+	}
+
+	t.serveOpenFile(w, r, f, fp, displayName, opts...)
+}
+
+// isMetadataPath reports whether the last element of path names a metadata file, ignoring case for file systems which
+// do.
+func isMetadataPath(path string) bool {
+	return IsMetadataFile(strings.ToLower(filepath.Base(filepath.FromSlash(path))))
+}
+
+// openInBase opens the file name inside base, as resolved by ResolvePath, and returns it along with its resolved path.
+// The name is resolved again once the file is open and must still lead to it, so a symbolic link swapped in between
+// resolving and opening can not lead outside of base.
+func (t *Tools) openInBase(base, name string) (*os.File, string, error) {
+	fp, err := t.ResolvePath(base, name)
+	if err != nil {
+		return nil, "", err
+	}
+
+	f, err := os.Open(fp)
+	if err != nil {
+		return nil, "", err
+	}
+
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, "", err
+	}
+
+	again, err := t.ResolvePath(base, name)
+	if err != nil {
+		f.Close()
+		return nil, "", err
+	}
+
+	if current, err := os.Stat(again); err != nil || !os.SameFile(info, current) {
+		f.Close()
+		return nil, "", ErrPathEscapesRoot
+	}
+
+	return f, fp, nil
+}
+
+// DownloadFS lets the user download the file name from fsys, which may be any fs.FS such as an embed.FS or os.DirFS. Range
+// requests, conditional requests and content type detection are handled by http.ServeContent, except for files which can
+// not seek, which are streamed whole as DownloadReader does. A JSON 403 is sent for invalid names and a JSON 404 for files
+// which do not exist.
+func (t *Tools) DownloadFS(w http.ResponseWriter, r *http.Request, fsys fs.FS, name, displayName string, opts ...DownloadOptions) {
+	w = t.throttleDownload(w, r)
+
+	name = strings.TrimPrefix(name, "/")
+	if !fs.ValidPath(name) {
+		t.downloadError(w, ErrPathEscapesRoot)
+		return
+	}
+
+	f, err := fsys.Open(name)
+	if err != nil {
+		t.downloadError(w, err)
+		return
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil || info.IsDir() {
+		t.downloadError(w, os.ErrNotExist)
+		return
+	}
+
+	if displayName == "" {
+		displayName = info.Name()
+	}
+
+	//	files which can not seek are streamed as they are, without range requests
+	content, ok := f.(io.ReadSeeker)
+	if !ok {
+		if !info.ModTime().IsZero() {
+			w.Header().Set("Last-Modified", info.ModTime().UTC().Format(http.TimeFormat))
+		}
+		_ = t.streamDownload(w, r, f, info.Size(), displayName, opts...)
+		return
+	}
+
+	t.serveDownload(w, r, downloadContent{
+		name:    info.Name(),
+		modtime: info.ModTime(),
+		content: content,
+		sibling: func(suffix string) (fs.File, error) { return fsys.Open(name + suffix) },
+	}, displayName, opts...)
+}
+
+// DownloadReader lets the user download content, such as a report generated in memory or streamed from a database, as a
+// file named displayName. Seekable content is served through http.ServeContent, with support for range and conditional
+// requests, and its size is found by seeking, so size is ignored. Other readers are streamed as they are, with a
+// Content-Length only when size is not negative; exactly size bytes are then sent, and content ending early is reported
+// as an error.
+func (t *Tools) DownloadReader(w http.ResponseWriter, r *http.Request, content io.Reader, size int64, displayName string, opts ...DownloadOptions) error {
+	w = t.throttleDownload(w, r)
+
+	var opt DownloadOptions
+	if len(opts) > 0 {
+		opt = opts[0]
+	}
+
+	if rs, ok := content.(io.ReadSeeker); ok {
+		t.serveDownload(w, r, downloadContent{name: displayName, modtime: opt.ModTime, content: rs}, displayName, opts...)
+		return nil
+	}
+
+	return t.streamDownload(w, r, content, size, displayName, opts...)
+}
+
+// streamDownload sends content as a download named displayName, exactly size bytes of it when size is not negative.
+func (t *Tools) streamDownload(w http.ResponseWriter, r *http.Request, content io.Reader, size int64, displayName string, opts ...DownloadOptions) error {
+	t.setDownloadHeaders(w, displayName, opts...)
+
+	if w.Header().Get("Content-Type") == "" {
+		ctype := mime.TypeByExtension(filepath.Ext(displayName))
+		if ctype == "" {
+			buff := make([]byte, 512)
+			n, err := io.ReadFull(content, buff)
+			if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+				return err
+			}
+			ctype = http.DetectContentType(buff[:n])
+			content = io.MultiReader(bytes.NewReader(buff[:n]), content)
+		}
+		w.Header().Set("Content-Type", ctype)
+	}
+
+	if size >= 0 {
+		w.Header().Set("Content-Length", strconv.FormatInt(size, 10))
+	}
+	w.WriteHeader(http.StatusOK)
+
+	if r.Method == http.MethodHead {
+		return nil
+	}
+
+	if size < 0 {
+		_, err := io.Copy(w, content)
+		return err
+	}
+
+	n, err := io.CopyN(w, content, size)
+	if err == io.EOF {
+		return fmt.Errorf("content ended after %d of %d bytes", n, size)
+	}
+	return err
+}
+
+// downloadContent is the content of a download along with what http.ServeContent needs to know about it.
+type downloadContent struct {
+	// name is used to detect the content type.
+	name string
+	// modtime is used for conditional requests. It is ignored when zero.
+	modtime time.Time
+	content io.ReadSeeker
+	// sibling opens the file named after the content with suffix appended, if the content comes from a file.
+	sibling func(suffix string) (fs.File, error)
+}
+
+// serveFile sends the file at pathName on the OS file system as a download named displayName.
+func (t *Tools) serveFile(w http.ResponseWriter, r *http.Request, pathName, displayName string, opts ...DownloadOptions) {
+	f, err := os.Open(pathName)
+	if err != nil {
+		t.downloadError(w, err)
+		return
+	}
+	defer f.Close()
+
+	t.serveOpenFile(w, r, f, pathName, displayName, opts...)
+}
+
+// serveOpenFile sends f, opened from pathName on the OS file system, as a download named displayName, offloading it to
+// the web server when configured to.
+func (t *Tools) serveOpenFile(w http.ResponseWriter, r *http.Request, f *os.File, pathName, displayName string, opts ...DownloadOptions) {
+	w = t.throttleDownload(w, r)
+
+	info, err := f.Stat()
+	if err != nil || info.IsDir() {
+		t.downloadError(w, os.ErrNotExist)
+		return
+	}
+
+	displayName = t.restoreDisplayName(w, pathName, displayName)
+	if t.OffloadMode != OffloadNone {
+		t.setDownloadHeaders(w, displayName, opts...)
+		if t.offload(w, pathName) {
+			return
+		}
+	}
+
+	dir, base := filepath.Split(pathName)
+	t.serveDownload(w, r, downloadContent{
+		name:    info.Name(),
+		modtime: info.ModTime(),
+		content: f,
+		sibling: func(suffix string) (fs.File, error) {
+			f, _, err := t.openInBase(dir, base+suffix)
+			if err != nil {
+				return nil, err
+			}
+			return f, nil
+		},
+	}, displayName, opts...)
+}
+
+// serveDownload sends dc as a download named displayName. Precompressed siblings are preferred when t.ServePrecompressed
+// is set, and compressible content is gzipped on the fly when t.CompressDownloads is set and the client accepts it.
+func (t *Tools) serveDownload(w http.ResponseWriter, r *http.Request, dc downloadContent, displayName string, opts ...DownloadOptions) {
+	t.setDownloadHeaders(w, displayName, opts...)
+
+	if !t.ServePrecompressed && !t.CompressDownloads {
+		http.ServeContent(w, r, dc.name, dc.modtime, dc.content)
+		return
+	}
+
+	w.Header().Add("Vary", "Accept-Encoding")
+	if err := setContentType(w, dc.name, dc.content); err != nil {
+		t.downloadError(w, err)
+		return
+	}
+
+	if t.ServePrecompressed && dc.sibling != nil && t.servePrecompressed(w, r, dc) {
+		return
+	}
+
+	//	compressing on the fly gives up ranges, so conditional and Range requests are left to
+	//	http.ServeContent and answered uncompressed
+	if t.CompressDownloads && acceptsEncoding(r, "gzip") && isCompressible(w.Header().Get("Content-Type")) && !isConditional(r) {
+		w.Header().Set("Content-Encoding", "gzip")
+		w.Header().Del("Content-Length")
+		if !dc.modtime.IsZero() {
+			w.Header().Set("Last-Modified", dc.modtime.UTC().Format(http.TimeFormat))
+		}
+		w.WriteHeader(http.StatusOK)
+		if r.Method == http.MethodHead {
+			return
+		}
+
+		gw := gzip.NewWriter(w)
+		if _, err := io.Copy(gw, dc.content); err == nil {
+			_ = gw.Close()
+		}
+		return
+	}
+
+	http.ServeContent(w, r, dc.name, dc.modtime, dc.content)
+}
+
+// restoreDisplayName returns displayName, or when it is empty, the original name of the file at pathName taken from its
+// upload metadata. The stored content type is set on the response as well.
+func (t *Tools) restoreDisplayName(w http.ResponseWriter, pathName, displayName string) string {
+	if displayName != "" {
+		return displayName
+	}
+
+	meta, err := t.ReadFileMetadata(filepath.Dir(pathName), filepath.Base(pathName))
+	if err != nil {
+		return filepath.Base(pathName)
+	}
+
+	if meta.ContentType != "" {
+		w.Header().Set("Content-Type", meta.ContentType)
+	}
+
+	return meta.OriginalFileName
+}
+
+// downloadError sends the JSON error response matching an error raised while looking up a file to download.
+func (t *Tools) downloadError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, ErrPathEscapesRoot), errors.Is(err, os.ErrPermission):
+		_ = t.ErrorJSON(w, errors.New("access to the requested file is forbidden"), http.StatusForbidden)
+	default:
+		_ = t.ErrorJSON(w, errors.New("the requested file does not exist"), http.StatusNotFound)
+	}
+}
+
+// ContentDisposition returns a Content-Disposition header value for filename following RFC 6266. Names which are not plain
+// ASCII get an ASCII fallback in filename and the exact name, encoded as per RFC 5987, in filename*.
+func (t *Tools) ContentDisposition(filename string, inline bool) string {
+	disposition := "attachment"
+	if inline {
+		disposition = "inline"
+	}
+
+	if filename == "" {
+		return disposition
+	}
+
+	var fallback strings.Builder
+	needsExtended := false
+	for _, c := range filename {
+		switch {
+		case c < 0x20 || c == 0x7f || c == '"' || c == '\\' || c > unicode.MaxASCII:
+			fallback.WriteByte('_')
+			needsExtended = true
+		default:
+			fallback.WriteRune(c)
+		}
+	}
+
+	value := fmt.Sprintf("%s; filename=\"%s\"", disposition, fallback.String())
+	if needsExtended && utf8.ValidString(filename) {
+		value += "; filename*=UTF-8''" + encodeRFC5987(filename)
+	}
+
+	return value
+}
+
+// encodeRFC5987 percent-encodes every byte of s which is not an attr-char as defined by RFC 5987.
+func encodeRFC5987(s string) string {
+	const hex = "0123456789ABCDEF"
+
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if ('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z') || ('0' <= c && c <= '9') || strings.IndexByte("!#$&+-.^_`|~", c) >= 0 {
+			b.WriteByte(c)
+			continue
+		}
+		b.WriteByte('%')
+		b.WriteByte(hex[c>>4])
+		b.WriteByte(hex[c&0x0f])
+	}
+
+	return b.String()
+}
+
+// setDownloadHeaders sets the Content-Disposition and, if overridden, the Content-Type headers of a download.
+func (t *Tools) setDownloadHeaders(w http.ResponseWriter, displayName string, opts ...DownloadOptions) {
+	var opt DownloadOptions
+	if len(opts) > 0 {
+		opt = opts[0]
+	}
+
+	if opt.ContentType != "" {
+		w.Header().Set("Content-Type", opt.ContentType)
+	}
+	w.Header().Set("Content-Disposition", t.ContentDisposition(displayName, opt.Inline))
+}
@@ -0,0 +1,277 @@
+package toolkit
+
+import (
+	"embed"
+	"encoding/json"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+//go:embed testdata/pic.jpg
+var embeddedFiles embed.FS
+
+var downloadFileTests = []struct {
+	name       string
+	file       string
+	statusCode int
+}{
+	{name: "valid file", file: "pic.jpg", statusCode: http.StatusOK},
+	{name: "missing file", file: "nope.jpg", statusCode: http.StatusNotFound},
+	{name: "directory", file: "sub", statusCode: http.StatusNotFound},
+	{name: "parent traversal", file: "../download_test.go", statusCode: http.StatusForbidden},
+	{name: "deep traversal", file: "../../../../../../etc/passwd", statusCode: http.StatusForbidden},
+	{name: "hidden traversal", file: "sub/../../download_test.go", statusCode: http.StatusForbidden},
+	{name: "absolute path", file: "/etc/passwd", statusCode: http.StatusForbidden},
+	{name: "nul byte", file: "pic.jpg\x00.txt", statusCode: http.StatusForbidden},
+	{name: "symlink escape", file: "escape", statusCode: http.StatusForbidden},
+	{name: "symlink inside", file: "inside", statusCode: http.StatusOK},
+	{name: "sidecar", file: "pic.jpg.meta.json", statusCode: http.StatusNotFound},
+	{name: "manifest", file: ".manifest.json", statusCode: http.StatusNotFound},
+	{name: "sidecar in upper case", file: "PIC.JPG.META.JSON", statusCode: http.StatusNotFound},
+	{name: "symlink to sidecar", file: "meta-link", statusCode: http.StatusNotFound},
+}
+
+func TestTools_DownloadFile(t *testing.T) {
+	base := t.TempDir()
+
+	pic, err := os.ReadFile("./testdata/pic.jpg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(base, "pic.jpg"), pic, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir(filepath.Join(base, "sub"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	outside, err := filepath.Abs("./download_test.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(outside, filepath.Join(base, "escape")); err != nil {
+		t.Skip("symlinks not supported: ", err)
+	}
+	if err := os.Symlink(filepath.Join(base, "pic.jpg"), filepath.Join(base, "inside")); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"pic.jpg.meta.json", ".manifest.json"} {
+		if err := os.WriteFile(filepath.Join(base, name), []byte(`{}`), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Symlink(filepath.Join(base, "pic.jpg.meta.json"), filepath.Join(base, "meta-link")); err != nil {
+		t.Fatal(err)
+	}
+
+	var testTools Tools
+
+	for _, e := range downloadFileTests {
+		rr := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/", nil)
+
+		testTools.DownloadFile(rr, req, base, e.file, "rowdy-cat.jpg")
+
+		if rr.Code != e.statusCode {
+			t.Errorf("%s: expected status %d but got %d", e.name, e.statusCode, rr.Code)
+			continue
+		}
+
+		if e.statusCode == http.StatusOK {
+			if rr.Body.Len() != len(pic) {
+				t.Errorf("%s: wrong body length %d", e.name, rr.Body.Len())
+			}
+			continue
+		}
+
+		var payload JSONResponse
+		if err := json.NewDecoder(rr.Body).Decode(&payload); err != nil || !payload.Error {
+			t.Errorf("%s: expected a JSON error response", e.name)
+		}
+	}
+}
+
+var contentDispositionTests = []struct {
+	name     string
+	filename string
+	inline   bool
+	expected string
+}{
+	{name: "plain", filename: "rowdy-cat.jpg", expected: `attachment; filename="rowdy-cat.jpg"`},
+	{name: "inline", filename: "report.pdf", inline: true, expected: `inline; filename="report.pdf"`},
+	{name: "empty", filename: "", expected: `attachment`},
+	{name: "quotes", filename: `a"b\c.txt`, expected: `attachment; filename="a_b_c.txt"; filename*=UTF-8''a%22b%5Cc.txt`},
+	{name: "header injection", filename: "a\r\nSet-Cookie: x=y", expected: `attachment; filename="a__Set-Cookie: x=y"; filename*=UTF-8''a%0D%0ASet-Cookie%3A%20x%3Dy`},
+	{name: "german", filename: "Übersicht.pdf", expected: `attachment; filename="_bersicht.pdf"; filename*=UTF-8''%C3%9Cbersicht.pdf`},
+	{name: "japanese", filename: "報告.csv", expected: `attachment; filename="__.csv"; filename*=UTF-8''%E5%A0%B1%E5%91%8A.csv`},
+}
+
+func TestTools_ContentDisposition(t *testing.T) {
+	var testTools Tools
+
+	for _, e := range contentDispositionTests {
+		if got := testTools.ContentDisposition(e.filename, e.inline); got != e.expected {
+			t.Errorf("%s: expected %s but got %s", e.name, e.expected, got)
+		}
+	}
+}
+
+func TestTools_DownloadStaticFileOptions(t *testing.T) {
+	rr := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+
+	var testTools Tools
+	testTools.DownloadStaticFile(rr, req, "testdata/pic.jpg", "katze.jpg", DownloadOptions{Inline: true, ContentType: "application/octet-stream"})
+
+	if rr.Header().Get("Content-Disposition") != `inline; filename="katze.jpg"` {
+		t.Errorf("wrong content disposition: %s", rr.Header().Get("Content-Disposition"))
+	}
+
+	if rr.Header().Get("Content-Type") != "application/octet-stream" {
+		t.Errorf("content type not overridden: %s", rr.Header().Get("Content-Type"))
+	}
+}
+
+// unseekableFS hides the Seek method of the files of the wrapped file system.
+type unseekableFS struct {
+	fs.FS
+}
+
+func (u unseekableFS) Open(name string) (fs.File, error) {
+	f, err := u.FS.Open(name)
+	if err != nil {
+		return nil, err
+	}
+
+	return struct{ fs.File }{f}, nil
+}
+
+var downloadFSTests = []struct {
+	name       string
+	fsys       fs.FS
+	file       string
+	rangeReq   string
+	statusCode int
+	length     int
+}{
+	{name: "embed", fsys: embeddedFiles, file: "testdata/pic.jpg", statusCode: http.StatusOK, length: 91585},
+	{name: "dir", fsys: os.DirFS("testdata"), file: "pic.jpg", statusCode: http.StatusOK, length: 91585},
+	{name: "map", fsys: fstest.MapFS{"a.txt": {Data: []byte("hello")}}, file: "a.txt", statusCode: http.StatusOK, length: 5},
+	{name: "range", fsys: embeddedFiles, file: "testdata/pic.jpg", rangeReq: "bytes=0-99", statusCode: http.StatusPartialContent, length: 100},
+	{name: "unseekable", fsys: unseekableFS{embeddedFiles}, file: "testdata/pic.jpg", statusCode: http.StatusOK, length: 91585},
+	{name: "unseekable range", fsys: unseekableFS{embeddedFiles}, file: "testdata/pic.jpg", rangeReq: "bytes=0-99", statusCode: http.StatusOK, length: 91585},
+	{name: "missing", fsys: embeddedFiles, file: "testdata/nope.jpg", statusCode: http.StatusNotFound},
+	{name: "directory", fsys: embeddedFiles, file: "testdata", statusCode: http.StatusNotFound},
+	{name: "traversal", fsys: os.DirFS("testdata"), file: "../download.go", statusCode: http.StatusForbidden},
+}
+
+func TestTools_DownloadFS(t *testing.T) {
+	var testTools Tools
+
+	for _, e := range downloadFSTests {
+		rr := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/", nil)
+		if e.rangeReq != "" {
+			req.Header.Set("Range", e.rangeReq)
+		}
+
+		testTools.DownloadFS(rr, req, e.fsys, e.file, "")
+
+		if rr.Code != e.statusCode {
+			t.Errorf("%s: expected status %d but got %d", e.name, e.statusCode, rr.Code)
+			continue
+		}
+
+		if e.length > 0 && rr.Body.Len() != e.length {
+			t.Errorf("%s: expected %d bytes but got %d", e.name, e.length, rr.Body.Len())
+		}
+	}
+
+	rr := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+	testTools.DownloadFS(rr, req, embeddedFiles, "testdata/pic.jpg", "")
+
+	if rr.Header().Get("Content-Type") != "image/jpeg" {
+		t.Errorf("wrong content type: %s", rr.Header().Get("Content-Type"))
+	}
+
+	if rr.Header().Get("Content-Disposition") != `attachment; filename="pic.jpg"` {
+		t.Errorf("wrong content disposition: %s", rr.Header().Get("Content-Disposition"))
+	}
+}
+
+var downloadReaderTests = []struct {
+	name        string
+	body        string
+	seekable    bool
+	size        int64
+	displayName string
+	contentType string
+	length      string
+}{
+	{name: "seeker", body: "a,b\n1,2\n", seekable: true, size: -1, displayName: "report.csv", contentType: "text/csv; charset=utf-8", length: "8"},
+	{name: "known length", body: "a,b\n1,2\n", size: 8, displayName: "report.csv", contentType: "text/csv; charset=utf-8", length: "8"},
+	{name: "unknown length", body: "a,b\n1,2\n", size: -1, displayName: "report.csv", contentType: "text/csv; charset=utf-8", length: ""},
+	{name: "sniffed type", body: "%PDF-1.4 ...", size: -1, displayName: "export", contentType: "application/pdf", length: ""},
+}
+
+func TestTools_DownloadReader(t *testing.T) {
+	var testTools Tools
+
+	for _, e := range downloadReaderTests {
+		rr := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/", nil)
+
+		//	hide the Seek method of the reader unless the content should be seekable
+		var content io.Reader = strings.NewReader(e.body)
+		if !e.seekable {
+			content = io.MultiReader(content)
+		}
+
+		if err := testTools.DownloadReader(rr, req, content, e.size, e.displayName); err != nil {
+			t.Errorf("%s: %s", e.name, err)
+		}
+
+		if rr.Body.String() != e.body {
+			t.Errorf("%s: wrong body %q", e.name, rr.Body.String())
+		}
+
+		if got := rr.Header().Get("Content-Type"); got != e.contentType {
+			t.Errorf("%s: expected content type %s but got %s", e.name, e.contentType, got)
+		}
+
+		if got := rr.Header().Get("Content-Length"); got != e.length {
+			t.Errorf("%s: expected content length %q but got %q", e.name, e.length, got)
+		}
+
+		if !strings.HasPrefix(rr.Header().Get("Content-Disposition"), "attachment") {
+			t.Errorf("%s: missing content disposition", e.name)
+		}
+	}
+}
+
+func TestTools_DownloadReaderSize(t *testing.T) {
+	var testTools Tools
+
+	rr := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+	if err := testTools.DownloadReader(rr, req, io.MultiReader(strings.NewReader("a,b\n1,2\n")), 4, "report.csv"); err != nil {
+		t.Fatal(err)
+	}
+
+	if rr.Body.String() != "a,b\n" {
+		t.Errorf("expected only the first 4 bytes but got %q", rr.Body.String())
+	}
+
+	rr = httptest.NewRecorder()
+	if err := testTools.DownloadReader(rr, req, io.MultiReader(strings.NewReader("a,b\n")), 8, "report.csv"); err == nil {
+		t.Error("expected an error for content shorter than its size")
+	}
+}
@@ -0,0 +1,29 @@
+package toolkit
+
+import "net/http"
+
+// Response is the generic counterpart of JSONResponse, for data of a type known at compile time.
+type Response[T any] struct {
+	Error   bool   `json:"error"`
+	Message string `json:"message"`
+	Data    T      `json:"data,omitempty"`
+}
+
+// ReadJSON decodes the JSON body of r into a new value of type T and returns it. It follows the same rules as
+// Tools.ReadJSON, but a target which is not a pointer can no longer be passed by mistake.
+func ReadJSON[T any](t *Tools, w http.ResponseWriter, r *http.Request) (T, error) {
+	var data T
+	err := t.ReadJSON(w, r, &data)
+
+	return data, err
+}
+
+// WriteData sends data to the client as the Data of a Response, with the given status code and optional headers.
+func WriteData[T any](t *Tools, w http.ResponseWriter, status int, data T, headers ...http.Header) error {
+	return t.WriteJSON(w, status, Response[T]{Data: data}, headers...)
+}
+
+// WriteMessage sends a Response with the given message and data to the client.
+func WriteMessage[T any](t *Tools, w http.ResponseWriter, status int, message string, data T, headers ...http.Header) error {
+	return t.WriteJSON(w, status, Response[T]{Message: message, Data: data}, headers...)
+}
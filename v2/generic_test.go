@@ -0,0 +1,66 @@
+package toolkit
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type genericPayload struct {
+	Foo string `json:"foo"`
+}
+
+func TestReadJSON(t *testing.T) {
+	var testTools Tools
+
+	rr := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/", strings.NewReader(`{"foo": "bar"}`))
+
+	payload, err := ReadJSON[genericPayload](&testTools, rr, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if payload.Foo != "bar" {
+		t.Errorf("expected bar but got %s", payload.Foo)
+	}
+
+	req, _ = http.NewRequest("POST", "/", strings.NewReader(`{"foo": "bar"}`))
+	ptr, err := ReadJSON[*genericPayload](&testTools, rr, req)
+	if err != nil || ptr == nil || ptr.Foo != "bar" {
+		t.Errorf("failed to decode into a pointer type: %v", err)
+	}
+
+	req, _ = http.NewRequest("POST", "/", strings.NewReader(`{"foo": 1}`))
+	if _, err = ReadJSON[genericPayload](&testTools, rr, req); err == nil {
+		t.Error("expected an error for the wrong type")
+	}
+}
+
+func TestWriteData(t *testing.T) {
+	var testTools Tools
+
+	rr := httptest.NewRecorder()
+	if err := WriteMessage(&testTools, rr, http.StatusCreated, "created", genericPayload{Foo: "bar"}); err != nil {
+		t.Fatal(err)
+	}
+
+	var res Response[genericPayload]
+	if err := json.NewDecoder(rr.Body).Decode(&res); err != nil {
+		t.Fatal(err)
+	}
+
+	if rr.Code != http.StatusCreated || res.Error || res.Message != "created" || res.Data.Foo != "bar" {
+		t.Errorf("wrong response: %d %+v", rr.Code, res)
+	}
+
+	rr = httptest.NewRecorder()
+	if err := WriteData(&testTools, rr, http.StatusOK, []int{1, 2}); err != nil {
+		t.Fatal(err)
+	}
+
+	if strings.TrimSpace(rr.Body.String()) != `{"error":false,"message":"","data":[1,2]}` {
+		t.Errorf("wrong body: %s", rr.Body.String())
+	}
+}
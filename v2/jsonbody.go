@@ -0,0 +1,296 @@
+package toolkit
+
+import (
+	"bufio"
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"mime"
+	"net/http"
+	"reflect"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	// JSONErrUnsupportedEncoding is a request whose Content-Encoding is neither gzip nor deflate.
+	JSONErrUnsupportedEncoding JSONErrorKind = "unsupported_encoding"
+	// JSONErrBadEncoding is a compressed body which can not be decompressed.
+	JSONErrBadEncoding JSONErrorKind = "bad_encoding"
+	// JSONErrUnsupportedMediaType is a request whose Content-Type is not JSON, when Tools.RequireJSONContentType is set.
+	JSONErrUnsupportedMediaType JSONErrorKind = "unsupported_media_type"
+	// JSONErrUnsupportedCharset is a request whose Content-Type names a charset which can not be decoded.
+	JSONErrUnsupportedCharset JSONErrorKind = "unsupported_charset"
+)
+
+// utf8BOM is the byte order mark some clients put in front of UTF-8 bodies.
+var utf8BOM = []byte{0xEF, 0xBB, 0xBF}
+
+// readJSONBody reads the whole body of r, limited to maxBytes, as prepared by jsonBody.
+func (t *Tools) readJSONBody(w http.ResponseWriter, r *http.Request, maxBytes int) ([]byte, error) {
+	r.Body = http.MaxBytesReader(w, r.Body, int64(maxBytes))
+
+	body, err := t.jsonBody(r, int64(maxBytes), isJSONMediaType)
+	if err != nil {
+		return nil, err
+	}
+
+	raw, err := io.ReadAll(body)
+	if err != nil {
+		if jsonErr := newJSONError(err, maxBytes, raw); jsonErr != nil {
+			return nil, jsonErr
+		}
+		return nil, err
+	}
+
+	return raw, nil
+}
+
+// decodeJSON decodes raw, which must hold a single JSON value, into data following the settings of t.
+func (t *Tools) decodeJSON(raw []byte, data interface{}, maxBytes int) error {
+	_, err := t.decodeJSONDocument(raw, data, maxBytes, false, true)
+	return err
+}
+
+// decodeJSONDocument decodes raw into data as decodeJSON does, applying default tags only when defaults is set, and
+// returns the generic form of raw when it was needed to apply them, or when wantDoc is set, so it is parsed at most once.
+func (t *Tools) decodeJSONDocument(raw []byte, data interface{}, maxBytes int, wantDoc, defaults bool) (interface{}, error) {
+	if err := t.checkJSONStructure(raw, reflect.TypeOf(data)); err != nil {
+		return nil, err
+	}
+
+	if schema := t.schemaFor(data); schema != nil {
+		//	badly-formed JSON is left to the decoder, which reports where it is broken
+		var errs ValidationErrors
+		if err := schema.Validate(raw); errors.As(err, &errs) {
+			return nil, errs
+		}
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(raw))
+
+	if !t.AllowUnknownFields {
+		dec.DisallowUnknownFields()
+	}
+
+	if t.UseJSONNumber {
+		dec.UseNumber()
+	}
+
+	err := dec.Decode(data)
+	if err != nil {
+		var unmarshalTypeError *json.UnmarshalTypeError
+		if t.CollectTypeErrors && errors.As(err, &unmarshalTypeError) {
+			if errs := collectTypeErrors(raw, data); len(errs) > 1 {
+				return nil, errs
+			}
+		}
+		if jsonErr := newJSONError(err, maxBytes, raw); jsonErr != nil {
+			return nil, jsonErr
+		}
+		return nil, err
+	}
+
+	err = dec.Decode(&struct{}{})
+	if err != io.EOF {
+		return nil, &JSONError{Kind: JSONErrMultipleValues, Key: "multiple_values", Offset: dec.InputOffset(), Err: err, msg: "body must contain only one JSON value"}
+	}
+
+	defaults = defaults && hasDefaults(reflect.TypeOf(data), nil)
+
+	var doc interface{}
+	if wantDoc || defaults {
+		if doc, err = decodeJSONValue(raw); err != nil {
+			return nil, err
+		}
+	}
+
+	if defaults {
+		if err = applyDefaults(doc, data); err != nil {
+			return nil, err
+		}
+	}
+
+	if t.ValidateJSON {
+		return doc, t.Validate(data)
+	}
+
+	return doc, nil
+}
+
+// jsonBody returns the body of r ready to be decoded as JSON. The Content-Type is checked when t.RequireJSONContentType is
+// set, gzip and deflate bodies are decompressed up to maxBytes, bodies in ISO-8859-1 are converted to UTF-8, other
+// charsets are refused when t.RequireJSONContentType is set, and a leading UTF-8 BOM is dropped. accepts tells which
+// media types are JSON for the caller.
+func (t *Tools) jsonBody(r *http.Request, maxBytes int64, accepts func(mediaType string) bool) (io.Reader, error) {
+	body, err := decompressBody(r.Body, r.Header.Get("Content-Encoding"), maxBytes)
+	if err != nil {
+		return nil, err
+	}
+
+	contentType := r.Header.Get("Content-Type")
+	mediaType, params, err := mime.ParseMediaType(contentType)
+
+	if t.RequireJSONContentType && (err != nil || !accepts(mediaType)) {
+		return nil, &JSONError{Kind: JSONErrUnsupportedMediaType, Key: "unsupported_media_type", msg: "Content-Type header must be application/json"}
+	}
+
+	if err == nil {
+		switch charset := strings.ToLower(params["charset"]); charset {
+		case "", "utf-8", "utf8", "us-ascii":
+		case "iso-8859-1", "latin1":
+			body = &latin1Reader{r: body}
+		default:
+			if t.RequireJSONContentType {
+				return nil, unsupportedCharset(charset)
+			}
+		}
+	}
+
+	br := bufio.NewReader(body)
+	if prefix, err := br.Peek(len(utf8BOM)); err == nil && bytes.Equal(prefix, utf8BOM) {
+		_, _ = br.Discard(len(utf8BOM))
+	}
+
+	return br, nil
+}
+
+// decompressBody undoes the content codings listed in contentEncoding, in reverse order, and fails with a
+// http.MaxBytesError once more than maxBytes have been decompressed.
+func decompressBody(body io.Reader, contentEncoding string, maxBytes int64) (io.Reader, error) {
+	codings := strings.Split(contentEncoding, ",")
+	decompressed := false
+
+	for i := len(codings) - 1; i >= 0; i-- {
+		coding := strings.ToLower(strings.TrimSpace(codings[i]))
+
+		switch coding {
+		case "", "identity":
+			continue
+
+		case "gzip", "x-gzip":
+			gr, err := gzip.NewReader(body)
+			if err != nil {
+				return nil, badEncoding(err)
+			}
+			body = gr
+
+		case "deflate":
+			//	deflate is meant to be zlib wrapped, but some clients send raw deflate data
+			br := bufio.NewReader(body)
+			if header, err := br.Peek(2); err == nil && (uint16(header[0])<<8|uint16(header[1]))%31 == 0 && header[0]&0x0f == 8 {
+				zr, err := zlib.NewReader(br)
+				if err != nil {
+					return nil, badEncoding(err)
+				}
+				body = zr
+			} else {
+				body = flate.NewReader(br)
+			}
+
+		default:
+			return nil, &JSONError{
+				Kind:   JSONErrUnsupportedEncoding,
+				Key:    "unsupported_encoding",
+				Params: map[string]interface{}{"encoding": coding},
+				msg:    fmt.Sprintf("Content-Encoding %s is not supported", coding),
+			}
+		}
+
+		decompressed = true
+	}
+
+	if !decompressed {
+		return body, nil
+	}
+
+	return &decompressedReader{r: body, remaining: maxBytes, limit: maxBytes}, nil
+}
+
+// decompressedReader reads decompressed data, limiting its size and reporting corrupt input as a JSONError.
+type decompressedReader struct {
+	r         io.Reader
+	remaining int64
+	limit     int64
+}
+
+// Read reads decompressed data, failing with a http.MaxBytesError once the limit is exceeded.
+func (d *decompressedReader) Read(p []byte) (int, error) {
+	//	read one byte past the limit to tell a body of exactly the limit from a longer one; remaining+1 is not compared
+	//	against len(p) as it overflows when the limit is math.MaxInt64, as for streams without a body limit
+	if d.remaining < int64(len(p)) {
+		p = p[:d.remaining+1]
+	}
+
+	n, err := d.r.Read(p)
+	if int64(n) > d.remaining {
+		d.remaining = 0
+		return 0, &http.MaxBytesError{Limit: d.limit}
+	}
+	d.remaining -= int64(n)
+
+	var maxBytesError *http.MaxBytesError
+	if err != nil && err != io.EOF && !errors.As(err, &maxBytesError) {
+		err = badEncoding(err)
+	}
+
+	return n, err
+}
+
+// badEncoding returns the JSONError reported for a body which can not be decompressed, or which went over its size limit
+// before its compression header could be read.
+func badEncoding(err error) *JSONError {
+	var maxBytesError *http.MaxBytesError
+	if errors.As(err, &maxBytesError) {
+		return newJSONError(err, int(min(maxBytesError.Limit, math.MaxInt)), nil)
+	}
+
+	return &JSONError{Kind: JSONErrBadEncoding, Key: "bad_encoding", Err: err, msg: "body could not be decompressed"}
+}
+
+// isJSONMediaType reports whether mediaType is application/json or a structured syntax type such as application/ld+json.
+func isJSONMediaType(mediaType string) bool {
+	return mediaType == "application/json" ||
+		(strings.HasPrefix(mediaType, "application/") && strings.HasSuffix(mediaType, "+json"))
+}
+
+// latin1Reader converts a stream of ISO-8859-1 bytes to UTF-8.
+type latin1Reader struct {
+	r       io.Reader
+	pending []byte
+}
+
+// Read fills p with UTF-8, encoding every byte read as the rune of the same value.
+func (l *latin1Reader) Read(p []byte) (int, error) {
+	if len(l.pending) == 0 {
+		in := make([]byte, max(len(p)/2, 1))
+		n, err := l.r.Read(in)
+		if n == 0 {
+			return 0, err
+		}
+		for _, b := range in[:n] {
+			l.pending = utf8.AppendRune(l.pending, rune(b))
+		}
+	}
+
+	n := copy(p, l.pending)
+	l.pending = l.pending[n:]
+
+	return n, nil
+}
+
+// unsupportedCharset returns the JSONError reported for a body in a charset which can not be decoded.
+func unsupportedCharset(charset string) *JSONError {
+	return &JSONError{
+		Kind:   JSONErrUnsupportedCharset,
+		Key:    "unsupported_charset",
+		Params: map[string]interface{}{"charset": charset},
+		msg:    "charset " + charset + " is not supported, use utf-8",
+	}
+}
@@ -0,0 +1,137 @@
+package toolkit
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+var jsonContentTypeTests = []struct {
+	name        string
+	contentType string
+	body        string
+	require     bool
+	expected    string
+	kind        JSONErrorKind
+}{
+	{name: "json", contentType: "application/json", body: `{"foo": "bar"}`, require: true, expected: "bar"},
+	{name: "json with charset", contentType: "application/json; charset=UTF-8", body: `{"foo": "bar"}`, require: true, expected: "bar"},
+	{name: "structured syntax", contentType: "application/vnd.api+json", body: `{"foo": "bar"}`, require: true, expected: "bar"},
+	{name: "form post", contentType: "application/x-www-form-urlencoded", body: `{"foo": "bar"}`, require: true, kind: JSONErrUnsupportedMediaType},
+	{name: "text plain", contentType: "text/plain", body: `{"foo": "bar"}`, require: true, kind: JSONErrUnsupportedMediaType},
+	{name: "missing", contentType: "", body: `{"foo": "bar"}`, require: true, kind: JSONErrUnsupportedMediaType},
+	{name: "not required", contentType: "text/plain", body: `{"foo": "bar"}`, expected: "bar"},
+	{name: "latin1", contentType: "application/json; charset=iso-8859-1", body: "{\"foo\": \"Gr\xfc\xdfe\"}", expected: "Grüße"},
+	{name: "utf-16", contentType: "application/json; charset=utf-16", body: `{"foo": "bar"}`, require: true, kind: JSONErrUnsupportedCharset},
+	{name: "charset not checked", contentType: "application/json; charset=utf-16", body: `{"foo": "bar"}`, expected: "bar"},
+	{name: "bom", contentType: "application/json", body: "\xef\xbb\xbf{\"foo\": \"bar\"}", expected: "bar"},
+}
+
+func TestTools_ReadJSONContentType(t *testing.T) {
+	for _, e := range jsonContentTypeTests {
+		var testTools Tools
+		testTools.RequireJSONContentType = e.require
+
+		var decodedJSON struct {
+			Foo string `json:"foo"`
+		}
+
+		req, _ := http.NewRequest("POST", "/", strings.NewReader(e.body))
+		if e.contentType != "" {
+			req.Header.Set("Content-Type", e.contentType)
+		}
+
+		err := testTools.ReadJSON(httptest.NewRecorder(), req, &decodedJSON)
+
+		if e.kind == "" {
+			if err != nil || decodedJSON.Foo != e.expected {
+				t.Errorf("%s: expected %q but got %q and error %v", e.name, e.expected, decodedJSON.Foo, err)
+			}
+			continue
+		}
+
+		var jsonErr *JSONError
+		if !errors.As(err, &jsonErr) || jsonErr.Kind != e.kind {
+			t.Errorf("%s: expected a %s error but got %v", e.name, e.kind, err)
+			continue
+		}
+
+		if jsonErr.StatusCode() != http.StatusUnsupportedMediaType {
+			t.Errorf("%s: expected status 415 but got %d", e.name, jsonErr.StatusCode())
+		}
+	}
+}
+
+// compress returns s compressed with the given content coding.
+func compress(coding, s string) []byte {
+	var buf bytes.Buffer
+	var w io.WriteCloser
+
+	switch coding {
+	case "gzip":
+		w = gzip.NewWriter(&buf)
+	case "deflate":
+		w = zlib.NewWriter(&buf)
+	case "raw deflate":
+		w, _ = flate.NewWriter(&buf, flate.DefaultCompression)
+	}
+
+	_, _ = w.Write([]byte(s))
+	_ = w.Close()
+
+	return buf.Bytes()
+}
+
+var jsonEncodingTests = []struct {
+	name     string
+	encoding string
+	body     []byte
+	maxSize  int
+	kind     JSONErrorKind
+}{
+	{name: "gzip", encoding: "gzip", body: compress("gzip", `{"foo": "bar"}`)},
+	{name: "deflate", encoding: "deflate", body: compress("deflate", `{"foo": "bar"}`)},
+	{name: "raw deflate", encoding: "deflate", body: compress("raw deflate", `{"foo": "bar"}`)},
+	{name: "identity", encoding: "identity", body: []byte(`{"foo": "bar"}`)},
+	{name: "zip bomb", encoding: "gzip", body: compress("gzip", `{"foo": "`+strings.Repeat(" ", 100000)+`"}`), maxSize: 1024, kind: JSONErrTooLarge},
+	{name: "compressed too large", encoding: "gzip", body: compress("gzip", `{"foo": "bar"}`), maxSize: 10, kind: JSONErrTooLarge},
+	{name: "header over the limit", encoding: "gzip", body: compress("gzip", `{"foo": "bar"}`), maxSize: 5, kind: JSONErrTooLarge},
+	{name: "zlib header over the limit", encoding: "deflate", body: compress("deflate", `{"foo": "bar"}`), maxSize: 1, kind: JSONErrTooLarge},
+	{name: "corrupt", encoding: "gzip", body: []byte("not gzip at all"), kind: JSONErrBadEncoding},
+	{name: "unsupported", encoding: "br", body: []byte(`{"foo": "bar"}`), kind: JSONErrUnsupportedEncoding},
+}
+
+func TestTools_ReadJSONCompressed(t *testing.T) {
+	for _, e := range jsonEncodingTests {
+		var testTools Tools
+		testTools.MaxJsonSize = e.maxSize
+
+		var decodedJSON struct {
+			Foo string `json:"foo"`
+		}
+
+		req, _ := http.NewRequest("POST", "/", bytes.NewReader(e.body))
+		req.Header.Set("Content-Encoding", e.encoding)
+
+		err := testTools.ReadJSON(httptest.NewRecorder(), req, &decodedJSON)
+
+		if e.kind == "" {
+			if err != nil || decodedJSON.Foo != "bar" {
+				t.Errorf("%s: expected bar but got %q and error %v", e.name, decodedJSON.Foo, err)
+			}
+			continue
+		}
+
+		var jsonErr *JSONError
+		if !errors.As(err, &jsonErr) || jsonErr.Kind != e.kind {
+			t.Errorf("%s: expected a %s error but got %v", e.name, e.kind, err)
+		}
+	}
+}
@@ -0,0 +1,396 @@
+package toolkit
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// JSONErrorKind tells what went wrong while reading a JSON body.
+type JSONErrorKind string
+
+const (
+	// JSONErrSyntax is a body which is not valid JSON.
+	JSONErrSyntax JSONErrorKind = "syntax"
+	// JSONErrType is a JSON value which does not fit the type of its Go field.
+	JSONErrType JSONErrorKind = "type"
+	// JSONErrEmpty is an empty body.
+	JSONErrEmpty JSONErrorKind = "empty"
+	// JSONErrUnknownField is a key which matches no field of the target, when unknown fields are not allowed.
+	JSONErrUnknownField JSONErrorKind = "unknown_field"
+	// JSONErrTooLarge is a body larger than Tools.MaxJsonSize.
+	JSONErrTooLarge JSONErrorKind = "too_large"
+	// JSONErrMultipleValues is a body holding more than one JSON value.
+	JSONErrMultipleValues JSONErrorKind = "multiple_values"
+	// JSONErrInvalidTarget is a target which can not be decoded into, such as a nil or non-pointer value.
+	JSONErrInvalidTarget JSONErrorKind = "invalid_target"
+)
+
+// JSONError is the error returned by ReadJSON when the body can not be read or decoded. Use errors.As to get it.
+type JSONError struct {
+	Kind JSONErrorKind
+	// Field is the path of the offending field, when known.
+	Field string
+	// Offset is the number of bytes read before the error, when known.
+	Offset int64
+	// Line and Column locate Offset in the body, starting at 1. They are 0 when unknown.
+	Line   int
+	Column int
+	// Err is the underlying error.
+	Err error
+	// Key identifies the message of the error in a MessageCatalog, and Params holds the values of its placeholders besides
+	// field, offset, line and column. Key is empty for errors which have no entry in DefaultMessages.
+	Key    string
+	Params map[string]interface{}
+
+	msg string
+}
+
+// Error returns a message describing the error, fit to be sent to the client. JSONErrors built outside of the toolkit,
+// which have no message, fall back to the message of Err, or else to their Kind.
+func (e *JSONError) Error() string {
+	switch {
+	case e.msg != "":
+		return e.msg
+	case e.Err != nil:
+		return e.Err.Error()
+	case e.Kind != "":
+		return string(e.Kind)
+	default:
+		return "body contains invalid JSON"
+	}
+}
+
+// Unwrap returns the underlying error.
+func (e *JSONError) Unwrap() error {
+	return e.Err
+}
+
+// StatusCode returns the HTTP status code matching the kind of the error.
+func (e *JSONError) StatusCode() int {
+	switch e.Kind {
+	case JSONErrTooLarge, JSONErrElementTooLarge:
+		return http.StatusRequestEntityTooLarge
+	case JSONErrUnsupportedMediaType, JSONErrUnsupportedCharset, JSONErrUnsupportedEncoding:
+		return http.StatusUnsupportedMediaType
+	case JSONErrPatchConflict:
+		return http.StatusConflict
+	case JSONErrInvalidTarget:
+		return http.StatusInternalServerError
+	default:
+		return http.StatusBadRequest
+	}
+}
+
+// StatusCode returns 422, as the body was valid JSON but its content is not acceptable.
+func (v ValidationErrors) StatusCode() int {
+	return http.StatusUnprocessableEntity
+}
+
+// newJSONError converts an error returned by a json.Decoder into a JSONError. raw holds the bytes read so far, which are
+// used to turn offsets into lines and columns.
+func newJSONError(err error, maxBytes int, raw []byte) *JSONError {
+	var syntaxError *json.SyntaxError
+	var unmarshalTypeError *json.UnmarshalTypeError
+	var invalidUnmarshalError *json.InvalidUnmarshalError
+	var maxBytesError *http.MaxBytesError
+
+	var jsonErr *JSONError
+	if errors.As(err, &jsonErr) {
+		return jsonErr
+	}
+
+	e := &JSONError{Err: err}
+
+	switch {
+	case errors.As(err, &syntaxError):
+		e.Kind, e.Key, e.Offset = JSONErrSyntax, "syntax", syntaxError.Offset
+		e.msg = fmt.Sprintf("body contains badly-formed JSON (at character %d)", syntaxError.Offset)
+
+	case errors.Is(err, io.ErrUnexpectedEOF):
+		e.Kind, e.Key, e.Offset = JSONErrSyntax, "unexpected_eof", int64(len(raw))
+		e.msg = "body contains badly-formed JSON"
+
+	case errors.As(err, &unmarshalTypeError):
+		e.Kind, e.Field, e.Offset = JSONErrType, unmarshalTypeError.Field, unmarshalTypeError.Offset
+		if unmarshalTypeError.Field != "" {
+			e.Key = "type_field"
+			e.msg = fmt.Sprintf("body contains incorrect JSON type for field %q", unmarshalTypeError.Field)
+		} else {
+			e.Key = "type"
+			e.msg = fmt.Sprintf("body contains incorrect JSON (at character %d)", unmarshalTypeError.Offset)
+		}
+
+	case errors.Is(err, io.EOF):
+		e.Kind, e.Key = JSONErrEmpty, "empty"
+		e.msg = "body must not be empty"
+
+	case errors.As(err, &maxBytesError):
+		e.Kind, e.Key, e.Params = JSONErrTooLarge, "too_large", map[string]interface{}{"max_bytes": maxBytes}
+		e.msg = fmt.Sprintf("body must not be larger than %d bytes", maxBytes)
+
+	case errors.As(err, &invalidUnmarshalError):
+		e.Kind, e.Key, e.Params = JSONErrInvalidTarget, "invalid_target", map[string]interface{}{"error": err.Error()}
+		e.msg = fmt.Sprintf("error unmarshalling JSON: %s", err.Error())
+
+	//	encoding/json has no error type for unknown fields, so the message is the only way to recognize them.
+	case strings.HasPrefix(err.Error(), "json: unknown field "):
+		name := strings.TrimPrefix(err.Error(), "json: unknown field ")
+		if unquoted, uerr := strconv.Unquote(name); uerr == nil {
+			name = unquoted
+		}
+		e.Kind, e.Key, e.Field, e.Offset = JSONErrUnknownField, "unknown_field", name, int64(len(raw))
+		e.msg = fmt.Sprintf("body contains unknown key %q", name)
+
+	default:
+		return nil
+	}
+
+	if e.Offset > 0 && e.Offset <= int64(len(raw)) {
+		e.Line, e.Column = lineColumn(raw, e.Offset)
+	}
+
+	return e
+}
+
+// lineColumn returns the line and column, both starting at 1, of the last byte read when offset bytes of raw were read.
+func lineColumn(raw []byte, offset int64) (int, int) {
+	before := raw[:offset-1]
+	line := bytes.Count(before, []byte("\n")) + 1
+	column := len(before) - bytes.LastIndexByte(before, '\n')
+
+	return line, column
+}
+
+// JSONErrors holds every type mismatch of a body, as found by ReadJSON when Tools.CollectTypeErrors is set. Use errors.As
+// to get it; errors.As with a *JSONError still gets the first mismatch.
+type JSONErrors []*JSONError
+
+// Error joins the errors of every field.
+func (e JSONErrors) Error() string {
+	msgs := make([]string, len(e))
+	for i, err := range e {
+		msgs[i] = err.Error()
+	}
+
+	return strings.Join(msgs, "; ")
+}
+
+// Unwrap returns the errors of every field.
+func (e JSONErrors) Unwrap() []error {
+	errs := make([]error, len(e))
+	for i, err := range e {
+		errs[i] = err
+	}
+
+	return errs
+}
+
+// StatusCode returns 400, as for a single type mismatch.
+func (e JSONErrors) StatusCode() int {
+	return http.StatusBadRequest
+}
+
+// Map returns the messages keyed by the path of their field.
+func (e JSONErrors) Map() map[string]string {
+	return e.localizedMap(nil)
+}
+
+// localizedMap returns the messages keyed by the path of their field, translated by the first of catalogs which has them.
+func (e JSONErrors) localizedMap(catalogs []MessageCatalog) map[string]string {
+	m := make(map[string]string, len(e))
+	for _, err := range e {
+		if _, ok := m[err.Field]; !ok {
+			m[err.Field] = translate(catalogs, err.Key, err.params(), err.Error())
+		}
+	}
+
+	return m
+}
+
+var (
+	jsonUnmarshalerType = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+// collectTypeErrors returns a JSONError for every value of raw which does not fit the type of its field in data, sorted
+// by path. Paths are written as in items[2].qty.
+func collectTypeErrors(raw []byte, data interface{}) JSONErrors {
+	v, err := decodeJSONValue(raw)
+	if err != nil {
+		return nil
+	}
+
+	var errs JSONErrors
+	collectTypeMismatches(v, reflect.TypeOf(data), "", &errs)
+	sort.SliceStable(errs, func(i, j int) bool { return errs[i].Field < errs[j].Field })
+
+	return errs
+}
+
+// collectTypeMismatches walks the JSON value v alongside typ, recording the path of every value encoding/json would
+// refuse to decode into typ.
+func collectTypeMismatches(v interface{}, typ reflect.Type, p string, errs *JSONErrors) {
+	if v == nil {
+		return
+	}
+
+	for typ.Kind() == reflect.Pointer {
+		typ = typ.Elem()
+	}
+
+	mismatch := func() {
+		*errs = append(*errs, &JSONError{
+			Kind:  JSONErrType,
+			Key:   "type_field",
+			Field: p,
+			msg:   fmt.Sprintf("body contains incorrect JSON type for field %q", p),
+		})
+	}
+
+	ptr := reflect.PointerTo(typ)
+	if ptr.Implements(jsonUnmarshalerType) {
+		return
+	}
+	if ptr.Implements(textUnmarshalerType) {
+		if _, ok := v.(string); !ok {
+			mismatch()
+		}
+		return
+	}
+
+	switch typ.Kind() {
+	case reflect.Interface:
+
+	case reflect.Struct:
+		obj, ok := v.(map[string]interface{})
+		if !ok {
+			mismatch()
+			return
+		}
+		for _, key := range sortedKeys(obj) {
+			if sf, name, ok := structFieldByJSONName(typ, key); ok {
+				if _, opts, _ := strings.Cut(sf.Tag.Get("json"), ","); opts == "string" {
+					continue
+				}
+				collectTypeMismatches(obj[key], sf.Type, joinPath(p, name), errs)
+			}
+		}
+
+	case reflect.Map:
+		obj, ok := v.(map[string]interface{})
+		if !ok {
+			mismatch()
+			return
+		}
+		for _, key := range sortedKeys(obj) {
+			collectTypeMismatches(obj[key], typ.Elem(), joinPath(p, key), errs)
+		}
+
+	case reflect.Slice, reflect.Array:
+		if typ.Kind() == reflect.Slice && typ.Elem().Kind() == reflect.Uint8 {
+			if _, ok := v.(string); !ok {
+				mismatch()
+			}
+			return
+		}
+		arr, ok := v.([]interface{})
+		if !ok {
+			mismatch()
+			return
+		}
+		for i, item := range arr {
+			collectTypeMismatches(item, typ.Elem(), fmt.Sprintf("%s[%d]", p, i), errs)
+		}
+
+	case reflect.String:
+		if _, ok := v.(string); !ok {
+			mismatch()
+		}
+
+	case reflect.Bool:
+		if _, ok := v.(bool); !ok {
+			mismatch()
+		}
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if n, ok := v.(json.Number); !ok {
+			mismatch()
+		} else if _, err := strconv.ParseInt(n.String(), 10, typ.Bits()); err != nil {
+			mismatch()
+		}
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if n, ok := v.(json.Number); !ok {
+			mismatch()
+		} else if _, err := strconv.ParseUint(n.String(), 10, typ.Bits()); err != nil {
+			mismatch()
+		}
+
+	case reflect.Float32, reflect.Float64:
+		if n, ok := v.(json.Number); !ok {
+			mismatch()
+		} else if _, err := strconv.ParseFloat(n.String(), typ.Bits()); err != nil {
+			mismatch()
+		}
+	}
+}
+
+// structFieldByJSONName returns the field of the struct typ which encoding/json decodes the key into, along with its JSON
+// name. An exact match wins over a case-insensitive one.
+func structFieldByJSONName(typ reflect.Type, key string) (reflect.StructField, string, bool) {
+	var folded reflect.StructField
+	var foldedName string
+	found := false
+
+	for _, sf := range reflect.VisibleFields(typ) {
+		if !sf.IsExported() {
+			continue
+		}
+		if sf.Anonymous && sf.Tag.Get("json") == "" && indirectType(sf.Type).Kind() == reflect.Struct {
+			continue
+		}
+
+		name, ok := jsonFieldName(sf)
+		if !ok {
+			continue
+		}
+
+		if name == key {
+			return sf, name, true
+		}
+		if !found && strings.EqualFold(name, key) {
+			folded, foldedName, found = sf, name, true
+		}
+	}
+
+	return folded, foldedName, found
+}
+
+// indirectType returns typ without its pointers.
+func indirectType(typ reflect.Type) reflect.Type {
+	for typ.Kind() == reflect.Pointer {
+		typ = typ.Elem()
+	}
+
+	return typ
+}
+
+// sortedKeys returns the keys of obj in order.
+func sortedKeys(obj map[string]interface{}) []string {
+	keys := make([]string, 0, len(obj))
+	for k := range obj {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
@@ -0,0 +1,110 @@
+package toolkit
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+var jsonErrorTests = []struct {
+	name       string
+	json       string
+	maxSize    int
+	kind       JSONErrorKind
+	field      string
+	line       int
+	column     int
+	statusCode int
+}{
+	{name: "syntax", json: "{\n  \"foo\": \"bar\",\n  \"baz\" 1\n}", kind: JSONErrSyntax, line: 3, column: 9, statusCode: http.StatusBadRequest},
+	{name: "unexpected end", json: `{"foo": "bar"`, kind: JSONErrSyntax, statusCode: http.StatusBadRequest},
+	{name: "type", json: `{"foo": 1}`, kind: JSONErrType, field: "foo", line: 1, column: 9, statusCode: http.StatusBadRequest},
+	{name: "empty", json: ``, kind: JSONErrEmpty, statusCode: http.StatusBadRequest},
+	{name: "unknown field", json: `{"fooo": "bar"}`, kind: JSONErrUnknownField, field: "fooo", statusCode: http.StatusBadRequest},
+	{name: "too large", json: `{"foo": "bar"}`, maxSize: 5, kind: JSONErrTooLarge, statusCode: http.StatusRequestEntityTooLarge},
+	{name: "multiple values", json: `{"foo": "bar"}{"foo": "baz"}`, kind: JSONErrMultipleValues, statusCode: http.StatusBadRequest},
+}
+
+func TestTools_ReadJSONErrors(t *testing.T) {
+	for _, e := range jsonErrorTests {
+		var testTools Tools
+		testTools.MaxJsonSize = e.maxSize
+
+		var decodedJSON struct {
+			Foo string `json:"foo"`
+		}
+
+		req, _ := http.NewRequest("POST", "/", strings.NewReader(e.json))
+		err := testTools.ReadJSON(httptest.NewRecorder(), req, &decodedJSON)
+
+		var jsonErr *JSONError
+		if !errors.As(err, &jsonErr) {
+			t.Errorf("%s: expected a JSONError but got %v", e.name, err)
+			continue
+		}
+
+		if jsonErr.Kind != e.kind || jsonErr.Field != e.field {
+			t.Errorf("%s: expected kind %s and field %q but got %s and %q", e.name, e.kind, e.field, jsonErr.Kind, jsonErr.Field)
+		}
+
+		if e.line != 0 && (jsonErr.Line != e.line || jsonErr.Column != e.column) {
+			t.Errorf("%s: expected line %d column %d but got %d:%d", e.name, e.line, e.column, jsonErr.Line, jsonErr.Column)
+		}
+
+		rr := httptest.NewRecorder()
+		_ = testTools.ErrorJSON(rr, fmt.Errorf("wrapped: %w", err))
+		if rr.Code != e.statusCode {
+			t.Errorf("%s: expected status %d but got %d", e.name, e.statusCode, rr.Code)
+		}
+	}
+}
+
+func TestTools_ReadJSONInvalidTarget(t *testing.T) {
+	var testTools Tools
+
+	var notAPointer struct{}
+	req, _ := http.NewRequest("POST", "/", strings.NewReader(`{}`))
+	err := testTools.ReadJSON(httptest.NewRecorder(), req, notAPointer)
+
+	var jsonErr *JSONError
+	if !errors.As(err, &jsonErr) || jsonErr.Kind != JSONErrInvalidTarget || jsonErr.StatusCode() != http.StatusInternalServerError {
+		t.Errorf("expected an invalid target error but got %v", err)
+	}
+}
+
+func TestTools_ErrorJSONStatus(t *testing.T) {
+	var testTools Tools
+
+	rr := httptest.NewRecorder()
+	_ = testTools.ErrorJSON(rr, ValidationErrors{{Field: "foo", Message: "is required"}})
+	if rr.Code != http.StatusUnprocessableEntity {
+		t.Errorf("expected 422 for validation errors but got %d", rr.Code)
+	}
+
+	rr = httptest.NewRecorder()
+	_ = testTools.ErrorJSON(rr, &JSONError{Kind: JSONErrTooLarge}, http.StatusTeapot)
+	if rr.Code != http.StatusTeapot {
+		t.Errorf("explicit status code was not used, got %d", rr.Code)
+	}
+}
+
+var jsonErrorMessageTests = []struct {
+	name string
+	err  *JSONError
+	want string
+}{
+	{name: "wrapped error", err: &JSONError{Kind: JSONErrSyntax, Err: errors.New("unexpected token")}, want: "unexpected token"},
+	{name: "kind only", err: &JSONError{Kind: JSONErrTooLarge}, want: "too_large"},
+	{name: "empty", err: &JSONError{}, want: "body contains invalid JSON"},
+}
+
+func TestJSONError_Error(t *testing.T) {
+	for _, e := range jsonErrorMessageTests {
+		if got := e.err.Error(); got != e.want {
+			t.Errorf("%s: expected %q but got %q", e.name, e.want, got)
+		}
+	}
+}
@@ -0,0 +1,322 @@
+package toolkit
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+// JSONErrElementTooLarge is an element of a JSON stream larger than JSONStreamOptions.MaxElementSize.
+const JSONErrElementTooLarge JSONErrorKind = "element_too_large"
+
+// streamReadSlack is how far ahead of the current element the decoder of a JSON array may read before the element is
+// considered too large.
+const streamReadSlack = 64 * 1024
+
+// JSONStreamOptions holds the optional settings of ReadJSONStream.
+type JSONStreamOptions struct {
+	// MaxBodySize limits the size of the whole body. It is unlimited when zero.
+	MaxBodySize int64
+	// MaxElementSize limits the size of every element. It defaults to Tools.MaxJsonSize, or 1 MB.
+	MaxElementSize int
+	// ContinueOnError goes on with the next element when one can not be decoded, instead of stopping.
+	ContinueOnError bool
+}
+
+// ElementError is the error of a single element of a JSON stream.
+type ElementError struct {
+	// Index is the position of the element in the array, or among the non-blank lines of NDJSON, starting at 0.
+	Index int
+	Err   error
+}
+
+// Error returns the index of the element followed by the reason it was refused.
+func (e *ElementError) Error() string {
+	return fmt.Sprintf("element %d: %s", e.Index, e.Err)
+}
+
+// Unwrap returns the error of the element.
+func (e *ElementError) Unwrap() error {
+	return e.Err
+}
+
+// StatusCode returns the status code of the error of the element.
+func (e *ElementError) StatusCode() int {
+	var jsonErr *JSONError
+	if errors.As(e.Err, &jsonErr) {
+		return jsonErr.StatusCode()
+	}
+
+	return http.StatusBadRequest
+}
+
+// ElementErrors holds the errors of every element skipped by ReadJSONStream with ContinueOnError.
+type ElementErrors []*ElementError
+
+// Error joins the errors of every element.
+func (e ElementErrors) Error() string {
+	msgs := make([]string, len(e))
+	for i, err := range e {
+		msgs[i] = err.Error()
+	}
+
+	return strings.Join(msgs, "; ")
+}
+
+// StatusCode returns 422, as the stream was read but some of its elements were not acceptable.
+func (e ElementErrors) StatusCode() int {
+	return http.StatusUnprocessableEntity
+}
+
+// ReadJSONStream reads a body holding either a top-level JSON array or NDJSON, one JSON value per line, without loading
+// it all in memory. Every element is decoded into a T, following the rules of Tools.ReadJSON, and handed to fn with its
+// index. NDJSON is expected when the Content-Type is application/x-ndjson or application/jsonl, or when the body does not
+// start with "[". A bad element stops the stream with an ElementError, unless opts sets ContinueOnError, in which case
+// the errors of all skipped elements are returned as ElementErrors once the stream is over. Syntax errors inside an array
+// and errors returned by fn always stop the stream, and so does an array element which goes more than 64 KB beyond
+// MaxElementSize, as the rest of the array can not be found without reading it whole.
+func ReadJSONStream[T any](t *Tools, w http.ResponseWriter, r *http.Request, fn func(index int, v T) error, opts ...JSONStreamOptions) error {
+	var opt JSONStreamOptions
+	if len(opts) > 0 {
+		opt = opts[0]
+	}
+
+	maxBody := opt.MaxBodySize
+	if maxBody <= 0 {
+		maxBody = math.MaxInt64
+	} else {
+		r.Body = http.MaxBytesReader(w, r.Body, maxBody)
+	}
+
+	maxElement := opt.MaxElementSize
+	if maxElement <= 0 {
+		maxElement = t.maxJSONBytes()
+	}
+
+	body, err := t.jsonBody(r, maxBody, isJSONStreamMediaType)
+	if err != nil {
+		return err
+	}
+	br := bufio.NewReader(body)
+
+	var skipped ElementErrors
+	handle := func(index int, raw []byte, err error) error {
+		if err == nil {
+			var v T
+			if err = t.decodeJSON(raw, &v, maxElement); err == nil {
+				return fn(index, v)
+			}
+		}
+
+		elementErr := &ElementError{Index: index, Err: err}
+		if !opt.ContinueOnError {
+			return elementErr
+		}
+		skipped = append(skipped, elementErr)
+
+		return nil
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType == "application/x-ndjson" || mediaType == "application/jsonl" || !startsWithArray(br) {
+		err = readNDJSON(br, maxElement, handle)
+	} else {
+		err = readJSONArray(br, maxElement, handle)
+	}
+	if err != nil {
+		var elementErr *ElementError
+		if errors.As(err, &elementErr) {
+			return err
+		}
+		if jsonErr := newJSONError(err, int(min(maxBody, math.MaxInt)), nil); jsonErr != nil {
+			return jsonErr
+		}
+		return err
+	}
+
+	if len(skipped) > 0 {
+		return skipped
+	}
+
+	return nil
+}
+
+// readJSONArray calls handle with every element of the JSON array read from br.
+func readJSONArray(br *bufio.Reader, maxElement int, handle func(index int, raw []byte, err error) error) error {
+	cr := &countingReader{r: br}
+	dec := json.NewDecoder(cr)
+
+	if _, err := dec.Token(); err != nil {
+		return err
+	}
+
+	for index := 0; dec.More(); index++ {
+		//	the decoder reads ahead, so the element may only be refused once it is well beyond the limit
+		start := dec.InputOffset()
+		cr.limit = start + int64(maxElement) + streamReadSlack
+
+		var raw json.RawMessage
+		if err := dec.Decode(&raw); err != nil {
+			var maxBytesError *http.MaxBytesError
+			switch {
+			case errors.Is(err, errElementTooLarge):
+				return &ElementError{Index: index, Err: elementTooLarge(maxElement)}
+			case errors.As(err, &maxBytesError):
+				return err
+			default:
+				return &ElementError{Index: index, Err: streamError(err, maxElement)}
+			}
+		}
+
+		if len(raw) > maxElement {
+			if err := handle(index, nil, elementTooLarge(maxElement)); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if err := handle(index, raw, nil); err != nil {
+			return err
+		}
+	}
+
+	if _, err := dec.Token(); err != nil {
+		return err
+	}
+
+	if _, err := dec.Token(); err != io.EOF {
+		return &JSONError{Kind: JSONErrMultipleValues, Key: "multiple_arrays", Offset: dec.InputOffset(), msg: "body must contain only one JSON array"}
+	}
+
+	return nil
+}
+
+// readNDJSON calls handle with every non-blank line read from br.
+func readNDJSON(br *bufio.Reader, maxElement int, handle func(index int, raw []byte, err error) error) error {
+	for index := 0; ; {
+		line, tooLarge, err := readLine(br, maxElement)
+		if err != nil && err != io.EOF {
+			return err
+		}
+
+		if tooLarge {
+			if herr := handle(index, nil, elementTooLarge(maxElement)); herr != nil {
+				return herr
+			}
+			index++
+		} else if len(bytes.TrimSpace(line)) > 0 {
+			if herr := handle(index, line, nil); herr != nil {
+				return herr
+			}
+			index++
+		}
+
+		if err == io.EOF {
+			return nil
+		}
+	}
+}
+
+// readLine reads a line from br. Lines longer than maxBytes are skipped, and reported with tooLarge set.
+func readLine(br *bufio.Reader, maxBytes int) ([]byte, bool, error) {
+	var line []byte
+	tooLarge := false
+
+	for {
+		chunk, err := br.ReadSlice('\n')
+		if !tooLarge {
+			if len(line)+len(chunk) > maxBytes+2 {
+				tooLarge, line = true, nil
+			} else {
+				line = append(line, chunk...)
+			}
+		}
+
+		if err == bufio.ErrBufferFull {
+			continue
+		}
+
+		return line, tooLarge, err
+	}
+}
+
+// startsWithArray reports whether the first non-blank byte of br opens a JSON array, without consuming it.
+func startsWithArray(br *bufio.Reader) bool {
+	for i := 1; ; i++ {
+		b, err := br.Peek(i)
+		if err != nil || len(b) < i {
+			return false
+		}
+
+		switch b[i-1] {
+		case ' ', '\t', '\r', '\n':
+			if i >= br.Size() {
+				return false
+			}
+			continue
+		case '[':
+			return true
+		default:
+			return false
+		}
+	}
+}
+
+// isJSONStreamMediaType reports whether mediaType is JSON or NDJSON.
+func isJSONStreamMediaType(mediaType string) bool {
+	return isJSONMediaType(mediaType) || mediaType == "application/x-ndjson" || mediaType == "application/jsonl"
+}
+
+// errElementTooLarge is returned by a countingReader once its limit is reached.
+var errElementTooLarge = errors.New("element too large")
+
+// countingReader is a reader failing with errElementTooLarge once more than limit bytes have been read, if limit is set.
+type countingReader struct {
+	r     io.Reader
+	read  int64
+	limit int64
+}
+
+// Read reads from the underlying reader, counting the bytes read.
+func (c *countingReader) Read(p []byte) (int, error) {
+	if c.limit > 0 {
+		if c.read >= c.limit {
+			return 0, errElementTooLarge
+		}
+		//	a single read could otherwise go well past the limit
+		if remaining := c.limit - c.read; int64(len(p)) > remaining {
+			p = p[:remaining]
+		}
+	}
+
+	n, err := c.r.Read(p)
+	c.read += int64(n)
+
+	return n, err
+}
+
+// elementTooLarge returns the JSONError of an element larger than maxBytes.
+func elementTooLarge(maxBytes int) *JSONError {
+	return &JSONError{
+		Kind:   JSONErrElementTooLarge,
+		Key:    "element_too_large",
+		Params: map[string]interface{}{"max_bytes": maxBytes},
+		msg:    fmt.Sprintf("element must not be larger than %d bytes", maxBytes),
+	}
+}
+
+// streamError converts an error which broke a stream into a JSONError when possible.
+func streamError(err error, maxBytes int) error {
+	if jsonErr := newJSONError(err, maxBytes, nil); jsonErr != nil {
+		return jsonErr
+	}
+
+	return err
+}
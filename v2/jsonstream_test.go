@@ -0,0 +1,124 @@
+package toolkit
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type streamRecord struct {
+	ID  int    `json:"id"`
+	Qty int    `json:"qty"`
+	SKU string `json:"sku"`
+}
+
+var jsonStreamTests = []struct {
+	name        string
+	contentType string
+	body        string
+	opts        JSONStreamOptions
+	ids         []int
+	badIndexes  []int
+	stopped     bool
+	kind        JSONErrorKind
+}{
+	{name: "array", body: ` [{"id": 1}, {"id": 2}, {"id": 3}]`, ids: []int{1, 2, 3}},
+	{name: "empty array", body: `[]`, ids: nil},
+	{name: "ndjson", contentType: "application/x-ndjson", body: "{\"id\": 1}\n\n{\"id\": 2}\r\n{\"id\": 3}", ids: []int{1, 2, 3}},
+	{name: "sniffed ndjson", body: "{\"id\": 1}\n{\"id\": 2}\n", ids: []int{1, 2}},
+	{name: "bad type stops", body: `[{"id": 1}, {"id": "two"}, {"id": 3}]`, ids: []int{1}, badIndexes: []int{1}, stopped: true},
+	{name: "bad type continues", body: `[{"id": 1}, {"id": "two"}, {"id": 3}, {"ide": 4}]`, opts: JSONStreamOptions{ContinueOnError: true}, ids: []int{1, 3}, badIndexes: []int{1, 3}},
+	{name: "ndjson syntax continues", body: "{\"id\": 1}\n{\"id\": \n{\"id\": 3}\n", opts: JSONStreamOptions{ContinueOnError: true}, ids: []int{1, 3}, badIndexes: []int{1}},
+	{name: "array syntax stops", body: `[{"id": 1}, {"id": }, {"id": 3}]`, opts: JSONStreamOptions{ContinueOnError: true}, ids: []int{1}, badIndexes: []int{1}, stopped: true},
+	{name: "element too large", body: `[{"id": 1}, {"id": 2, "sku": "` + strings.Repeat("x", 100) + `"}, {"id": 3}]`, opts: JSONStreamOptions{MaxElementSize: 50, ContinueOnError: true}, ids: []int{1, 3}, badIndexes: []int{1}},
+	{name: "element far too large stops", body: `[{"id": 1}, {"id": 2, "sku": "` + strings.Repeat("x", 70*1024) + `"}, {"id": 3}]`, opts: JSONStreamOptions{MaxElementSize: 50, ContinueOnError: true}, ids: []int{1}, badIndexes: []int{1}, stopped: true},
+	{name: "line too large", body: "{\"id\": 1}\n{\"id\": 2, \"sku\": \"" + strings.Repeat("x", 100) + "\"}\n{\"id\": 3}", opts: JSONStreamOptions{MaxElementSize: 50, ContinueOnError: true}, ids: []int{1, 3}, badIndexes: []int{1}},
+	{name: "body too large", body: `[{"id": 1}, {"id": 2}, {"id": 3}]`, opts: JSONStreamOptions{MaxBodySize: 15}, ids: []int{1}, kind: JSONErrTooLarge},
+	{name: "trailing data", body: `[{"id": 1}] [`, ids: []int{1}, kind: JSONErrMultipleValues},
+}
+
+func TestReadJSONStream(t *testing.T) {
+	var testTools Tools
+
+	for _, e := range jsonStreamTests {
+		req, _ := http.NewRequest("POST", "/", strings.NewReader(e.body))
+		if e.contentType != "" {
+			req.Header.Set("Content-Type", e.contentType)
+		}
+
+		var ids []int
+		err := ReadJSONStream(&testTools, httptest.NewRecorder(), req, func(index int, v streamRecord) error {
+			ids = append(ids, v.ID)
+			return nil
+		}, e.opts)
+
+		if len(ids) != len(e.ids) {
+			t.Errorf("%s: expected ids %v but got %v", e.name, e.ids, ids)
+		} else {
+			for i := range ids {
+				if ids[i] != e.ids[i] {
+					t.Errorf("%s: expected ids %v but got %v", e.name, e.ids, ids)
+					break
+				}
+			}
+		}
+
+		if e.kind != "" {
+			var jsonErr *JSONError
+			if !errors.As(err, &jsonErr) || jsonErr.Kind != e.kind {
+				t.Errorf("%s: expected a %s error but got %v", e.name, e.kind, err)
+			}
+			continue
+		}
+
+		var badIndexes []int
+		var elementErr *ElementError
+		var elementErrs ElementErrors
+		switch {
+		case errors.As(err, &elementErrs):
+			for _, ee := range elementErrs {
+				badIndexes = append(badIndexes, ee.Index)
+			}
+			if e.stopped {
+				t.Errorf("%s: expected the stream to stop", e.name)
+			}
+		case errors.As(err, &elementErr):
+			badIndexes = append(badIndexes, elementErr.Index)
+			if !e.stopped {
+				t.Errorf("%s: expected the stream to go on", e.name)
+			}
+		case err != nil:
+			t.Errorf("%s: unexpected error %v", e.name, err)
+		}
+
+		if len(badIndexes) != len(e.badIndexes) {
+			t.Errorf("%s: expected bad indexes %v but got %v (%v)", e.name, e.badIndexes, badIndexes, err)
+			continue
+		}
+		for i := range badIndexes {
+			if badIndexes[i] != e.badIndexes[i] {
+				t.Errorf("%s: expected bad indexes %v but got %v", e.name, e.badIndexes, badIndexes)
+				break
+			}
+		}
+	}
+}
+
+func TestReadJSONStreamCallbackError(t *testing.T) {
+	var testTools Tools
+
+	stop := errors.New("stop")
+	req, _ := http.NewRequest("POST", "/", strings.NewReader(`[{"id": 1}, {"id": 2}]`))
+
+	calls := 0
+	err := ReadJSONStream(&testTools, httptest.NewRecorder(), req, func(index int, v streamRecord) error {
+		calls++
+		return stop
+	})
+
+	if !errors.Is(err, stop) || calls != 1 {
+		t.Errorf("expected the callback error to stop the stream, got %v after %d calls", err, calls)
+	}
+}
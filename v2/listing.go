@@ -0,0 +1,210 @@
+package toolkit
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"mime"
+	"net/http"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// defaultPerPage is the number of files listed per page when the request does not say.
+	defaultPerPage = 50
+	// maxPerPage is the largest number of files listed per page.
+	maxPerPage = 1000
+)
+
+// ListedFile is the information sent about every file by ListFilesHandler.
+type ListedFile struct {
+	Name        string    `json:"name"`
+	Size        int64     `json:"size"`
+	ModTime     time.Time `json:"modified"`
+	ContentType string    `json:"content_type"`
+}
+
+// FileListing is one page of files sent by ListFilesHandler.
+type FileListing struct {
+	Files   []ListedFile `json:"files"`
+	Page    int          `json:"page"`
+	PerPage int          `json:"per_page"`
+	Total   int          `json:"total"`
+}
+
+// ListFilesHandler returns a http.Handler listing the files of fsys as JSON, such as os.DirFS of an upload directory. The
+// query parameters are dir (the directory inside fsys, "." by default), glob (a path.Match pattern), sort (name, size or
+// modified), order (asc or desc), page and per_page, and hidden=true to include files starting with a dot. Metadata files
+// written by UploadFiles are never listed.
+func (t *Tools) ListFilesHandler(fsys fs.FS) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+
+		dir := q.Get("dir")
+		if dir == "" {
+			dir = "."
+		}
+		if !fs.ValidPath(dir) {
+			_ = t.ErrorJSON(w, ErrPathEscapesRoot, http.StatusForbidden)
+			return
+		}
+		if linked, err := throughSymlink(fsys, dir); err != nil {
+			_ = t.ErrorJSON(w, errors.New("the requested directory does not exist"), http.StatusNotFound)
+			return
+		} else if linked {
+			_ = t.ErrorJSON(w, ErrPathEscapesRoot, http.StatusForbidden)
+			return
+		}
+
+		glob := q.Get("glob")
+		if _, err := path.Match(glob, ""); err != nil {
+			_ = t.ErrorJSON(w, errors.New("invalid glob pattern"))
+			return
+		}
+
+		page, err := queryInt(q.Get("page"), 1)
+		if err != nil || page < 1 {
+			_ = t.ErrorJSON(w, errors.New("page must be a positive integer"))
+			return
+		}
+
+		perPage, err := queryInt(q.Get("per_page"), defaultPerPage)
+		if err != nil || perPage < 1 || perPage > maxPerPage {
+			_ = t.ErrorJSON(w, errors.New("per_page must be between 1 and 1000"))
+			return
+		}
+
+		less, err := fileOrder(q.Get("sort"), q.Get("order"))
+		if err != nil {
+			_ = t.ErrorJSON(w, err)
+			return
+		}
+
+		entries, err := fs.ReadDir(fsys, dir)
+		if err != nil {
+			_ = t.ErrorJSON(w, errors.New("the requested directory does not exist"), http.StatusNotFound)
+			return
+		}
+
+		showHidden := q.Get("hidden") == "true"
+		var files []ListedFile
+		for _, entry := range entries {
+			name := entry.Name()
+			if !entry.Type().IsRegular() || IsMetadataFile(name) || (!showHidden && strings.HasPrefix(name, ".")) {
+				continue
+			}
+			if glob != "" {
+				if ok, _ := path.Match(glob, name); !ok {
+					continue
+				}
+			}
+
+			info, err := entry.Info()
+			if err != nil {
+				continue
+			}
+			files = append(files, ListedFile{Name: name, Size: info.Size(), ModTime: info.ModTime()})
+		}
+
+		sort.SliceStable(files, func(i, j int) bool { return less(files[i], files[j]) })
+
+		listing := FileListing{Files: []ListedFile{}, Page: page, PerPage: perPage, Total: len(files)}
+		if start := (page - 1) * perPage; start < len(files) {
+			end := start + perPage
+			if end > len(files) {
+				end = len(files)
+			}
+			listing.Files = files[start:end]
+		}
+
+		for i := range listing.Files {
+			listing.Files[i].ContentType = detectFileType(fsys, path.Join(dir, listing.Files[i].Name))
+		}
+
+		_ = t.WriteJSON(w, http.StatusOK, JSONResponse{Data: listing})
+	})
+}
+
+// throughSymlink reports whether any element of the valid path dir is a symbolic link in fsys. Following one could
+// list a directory outside of the root of an os.DirFS, so the listing is refused instead of resolving where it leads.
+func throughSymlink(fsys fs.FS, dir string) (bool, error) {
+	if dir == "." {
+		return false, nil
+	}
+
+	parent := "."
+	for _, elem := range strings.Split(dir, "/") {
+		entries, err := fs.ReadDir(fsys, parent)
+		if err != nil {
+			return false, err
+		}
+
+		i := sort.Search(len(entries), func(i int) bool { return entries[i].Name() >= elem })
+		if i == len(entries) || entries[i].Name() != elem {
+			return false, fs.ErrNotExist
+		}
+		if entries[i].Type()&fs.ModeSymlink != 0 {
+			return true, nil
+		}
+
+		parent = path.Join(parent, elem)
+	}
+
+	return false, nil
+}
+
+// queryInt parses the integer query parameter s, returning def when it is empty.
+func queryInt(s string, def int) (int, error) {
+	if s == "" {
+		return def, nil
+	}
+
+	return strconv.Atoi(s)
+}
+
+// fileOrder returns the comparison used to sort listed files by the given field and order.
+func fileOrder(field, order string) (func(a, b ListedFile) bool, error) {
+	var less func(a, b ListedFile) bool
+
+	switch field {
+	case "", "name":
+		less = func(a, b ListedFile) bool { return a.Name < b.Name }
+	case "size":
+		less = func(a, b ListedFile) bool { return a.Size < b.Size }
+	case "modified":
+		less = func(a, b ListedFile) bool { return a.ModTime.Before(b.ModTime) }
+	default:
+		return nil, errors.New("sort must be one of name, size or modified")
+	}
+
+	switch order {
+	case "", "asc":
+		return less, nil
+	case "desc":
+		return func(a, b ListedFile) bool { return less(b, a) }, nil
+	default:
+		return nil, errors.New("order must be asc or desc")
+	}
+}
+
+// detectFileType returns the content type of the file name in fsys, from its extension or else from its first bytes.
+func detectFileType(fsys fs.FS, name string) string {
+	if ctype := mime.TypeByExtension(path.Ext(name)); ctype != "" {
+		return ctype
+	}
+
+	f, err := fsys.Open(name)
+	if err != nil {
+		return "application/octet-stream"
+	}
+	defer f.Close()
+
+	buff := make([]byte, 512)
+	n, _ := io.ReadFull(f, buff)
+
+	return http.DetectContentType(buff[:n])
+}
@@ -0,0 +1,139 @@
+package toolkit
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+var listingFS = fstest.MapFS{
+	"b.csv":          {Data: []byte("a,b\n"), ModTime: time.Unix(300, 0)},
+	"a.jpg":          {Data: []byte("123456"), ModTime: time.Unix(100, 0)},
+	"c":              {Data: []byte("%PDF-1.4"), ModTime: time.Unix(200, 0)},
+	".hidden":        {Data: []byte("x")},
+	".manifest.json": {Data: []byte("{}")},
+	"c.meta.json":    {Data: []byte("{}")},
+	"sub/d.txt":      {Data: []byte("d")},
+}
+
+var listingTests = []struct {
+	name       string
+	query      string
+	statusCode int
+	expected   []string
+	total      int
+}{
+	{name: "default", query: "", statusCode: http.StatusOK, expected: []string{"a.jpg", "b.csv", "c"}, total: 3},
+	{name: "by size desc", query: "?sort=size&order=desc", statusCode: http.StatusOK, expected: []string{"c", "a.jpg", "b.csv"}, total: 3},
+	{name: "by modified", query: "?sort=modified", statusCode: http.StatusOK, expected: []string{"a.jpg", "c", "b.csv"}, total: 3},
+	{name: "paginated", query: "?per_page=2&page=2", statusCode: http.StatusOK, expected: []string{"c"}, total: 3},
+	{name: "past last page", query: "?page=5", statusCode: http.StatusOK, expected: []string{}, total: 3},
+	{name: "glob", query: "?glob=*.csv", statusCode: http.StatusOK, expected: []string{"b.csv"}, total: 1},
+	{name: "hidden", query: "?hidden=true", statusCode: http.StatusOK, expected: []string{".hidden", "a.jpg", "b.csv", "c"}, total: 4},
+	{name: "sub directory", query: "?dir=sub", statusCode: http.StatusOK, expected: []string{"d.txt"}, total: 1},
+	{name: "missing directory", query: "?dir=nope", statusCode: http.StatusNotFound},
+	{name: "traversal", query: "?dir=../", statusCode: http.StatusForbidden},
+	{name: "bad sort", query: "?sort=owner", statusCode: http.StatusBadRequest},
+	{name: "bad page", query: "?page=0", statusCode: http.StatusBadRequest},
+	{name: "bad glob", query: "?glob=[", statusCode: http.StatusBadRequest},
+}
+
+func TestTools_ListFilesHandler(t *testing.T) {
+	var testTools Tools
+	handler := testTools.ListFilesHandler(listingFS)
+
+	for _, e := range listingTests {
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, httptest.NewRequest("GET", "/files"+e.query, nil))
+
+		if rr.Code != e.statusCode {
+			t.Errorf("%s: expected status %d but got %d", e.name, e.statusCode, rr.Code)
+			continue
+		}
+		if e.statusCode != http.StatusOK {
+			continue
+		}
+
+		var payload struct {
+			Data FileListing `json:"data"`
+		}
+		if err := json.NewDecoder(rr.Body).Decode(&payload); err != nil {
+			t.Fatal(err)
+		}
+
+		if payload.Data.Total != e.total || len(payload.Data.Files) != len(e.expected) {
+			t.Errorf("%s: expected %v of %d files but got %+v", e.name, e.expected, e.total, payload.Data)
+			continue
+		}
+
+		for i, f := range payload.Data.Files {
+			if f.Name != e.expected[i] {
+				t.Errorf("%s: expected %s at position %d but got %s", e.name, e.expected[i], i, f.Name)
+			}
+		}
+	}
+}
+
+func TestTools_ListFilesHandlerSymlink(t *testing.T) {
+	root, outside := t.TempDir(), t.TempDir()
+	if err := os.WriteFile(filepath.Join(outside, "secret.txt"), []byte("secret"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir(filepath.Join(root, "inner"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "inner", "x.txt"), []byte("x"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(outside, filepath.Join(root, "link")); err != nil {
+		t.Skip("symlinks not supported: ", err)
+	}
+	if err := os.Symlink(outside, filepath.Join(root, "inner", "deep")); err != nil {
+		t.Fatal(err)
+	}
+
+	var testTools Tools
+	handler := testTools.ListFilesHandler(os.DirFS(root))
+
+	for query, statusCode := range map[string]int{
+		"?dir=inner":      http.StatusOK,
+		"?dir=link":       http.StatusForbidden,
+		"?dir=inner/deep": http.StatusForbidden,
+		"?dir=inner/nope": http.StatusNotFound,
+	} {
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, httptest.NewRequest("GET", "/files"+query, nil))
+
+		if rr.Code != statusCode {
+			t.Errorf("%s: expected status %d but got %d", query, statusCode, rr.Code)
+		}
+		if strings.Contains(rr.Body.String(), "secret.txt") {
+			t.Errorf("%s: listed a file outside of the root", query)
+		}
+	}
+}
+
+func TestTools_ListFilesHandlerContentType(t *testing.T) {
+	var testTools Tools
+
+	rr := httptest.NewRecorder()
+	testTools.ListFilesHandler(listingFS).ServeHTTP(rr, httptest.NewRequest("GET", "/files", nil))
+
+	var payload struct {
+		Data FileListing `json:"data"`
+	}
+	_ = json.NewDecoder(rr.Body).Decode(&payload)
+
+	expected := map[string]string{"a.jpg": "image/jpeg", "b.csv": "text/csv; charset=utf-8", "c": "application/pdf"}
+	for _, f := range payload.Data.Files {
+		if f.ContentType != expected[f.Name] {
+			t.Errorf("%s: expected %s but got %s", f.Name, expected[f.Name], f.ContentType)
+		}
+	}
+}
@@ -0,0 +1,218 @@
+package toolkit
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// MessageCatalog maps message keys to the templates of their messages in one language. Templates refer to the parameters
+// of a message by name, in braces, as in `body contains unknown key "{field}"`.
+type MessageCatalog map[string]string
+
+// DefaultMessages is the English catalog of the messages of JSONError, listing every message key along with its
+// parameters, as a starting point for translations. It is for reference only: errors keep their own English message when
+// no registered catalog translates them. The messages of ValidationErrors are looked up as "validate." followed by the
+// rule, such as "validate.required", with the field and param parameters, and an ElementError is looked up as "element",
+// with the index of the element and the translated message of its error as parameters.
+var DefaultMessages = MessageCatalog{
+	"syntax":                    "body contains badly-formed JSON (at character {offset})",
+	"unexpected_eof":            "body contains badly-formed JSON",
+	"type":                      "body contains incorrect JSON (at character {offset})",
+	"type_field":                `body contains incorrect JSON type for field "{field}"`,
+	"empty":                     "body must not be empty",
+	"too_large":                 "body must not be larger than {max_bytes} bytes",
+	"invalid_target":            "error unmarshalling JSON: {error}",
+	"unknown_field":             `body contains unknown key "{field}"`,
+	"multiple_values":           "body must contain only one JSON value",
+	"multiple_arrays":           "body must contain only one JSON array",
+	"element_too_large":         "element must not be larger than {max_bytes} bytes",
+	"unsupported_media_type":    "Content-Type header must be application/json",
+	"unsupported_charset":       "charset {charset} is not supported, use utf-8",
+	"unsupported_encoding":      "Content-Encoding {encoding} is not supported",
+	"bad_encoding":              "body could not be decompressed",
+	"duplicate_key":             `body contains duplicate key "{field}"`,
+	"too_deep":                  "body must not be nested more than {max} levels deep",
+	"array_too_long":            "body contains an array with more than {max} elements",
+	"invalid_fields":            "body contains invalid fields",
+	"type_errors":               "body contains fields of the wrong type",
+	"bad_form":                  "body contains a badly-formed form",
+	"query_type":                `query parameter "{field}" must be a valid {type}`,
+	"query_type_list":           `query parameter "{field}" must be a list of valid {type} values`,
+	"form_type":                 `form field "{field}" must be a valid {type}`,
+	"form_type_list":            `form field "{field}" must be a list of valid {type} values`,
+	"path_type":                 `path value "{field}" must be a valid {type}`,
+	"path_type_list":            `path value "{field}" must be a list of valid {type} values`,
+	"invalid_content_type":      "Content-Type header is not valid",
+	"unsupported_content_type":  "Content-Type {media_type} is not supported",
+	"bad_body":                  "body contains badly-formed {media_type}",
+	"xml_syntax":                "body contains badly-formed XML (at line {line})",
+	"xml_type":                  "body contains incorrect XML: {error}",
+	"element":                   "element {index}: {error}",
+	"patch_invalid_json":        "patch is not valid JSON",
+	"patch_not_operations":      "patch must be an array of operations",
+	"patch_missing_path":        "operation {operation}: missing path",
+	"patch_missing_from":        "operation {operation}: missing from",
+	"patch_missing_value":       "operation {operation}: missing value",
+	"patch_invalid_value":       "operation {operation}: invalid value",
+	"patch_move_into_itself":    "operation {operation}: a value can not be moved into itself",
+	"patch_unknown_operation":   `operation {operation}: unknown operation "{op}"`,
+	"patch_invalid_pointer":     `operation {operation}: invalid JSON Pointer "{pointer}"`,
+	"patch_invalid_index":       `operation {operation}: invalid array index "{index}"`,
+	"patch_index_out_of_bounds": "operation {operation}: array index {index} is out of bounds",
+	"patch_path_not_found":      "operation {operation}: path {path} does not exist",
+	"patch_test_failed":         "operation {operation}: test failed at {path}",
+}
+
+// RegisterMessages registers the catalog of the language lang, such as "de" or "es-MX", used by LocalizeError and
+// LocalizedErrorJSON. Keys missing from the catalog fall back to the English message.
+func (t *Tools) RegisterMessages(lang string, catalog MessageCatalog) {
+	if t.messages == nil {
+		t.messages = make(map[string]MessageCatalog)
+	}
+	t.messages[strings.ToLower(lang)] = catalog
+}
+
+// LocalizeError returns the message of err in the language the client of r prefers, according to its Accept-Language
+// header and the catalogs registered with RegisterMessages. Errors without a translation keep their English message.
+func (t *Tools) LocalizeError(r *http.Request, err error) string {
+	return localize(t.catalogs(r), err)
+}
+
+// LocalizedErrorJSON sends err as ErrorJSON does, with its messages in the language the client of r prefers.
+func (t *Tools) LocalizedErrorJSON(w http.ResponseWriter, r *http.Request, err error, status ...int) error {
+	return t.writeErrorJSON(w, err, t.catalogs(r), status...)
+}
+
+// catalogs returns the registered catalogs of the languages accepted by r, the preferred one first. A language also
+// matches the catalog of its base language, so de-AT falls back to de.
+func (t *Tools) catalogs(r *http.Request) []MessageCatalog {
+	if len(t.messages) == 0 {
+		return nil
+	}
+
+	var catalogs []MessageCatalog
+	for _, lang := range acceptedLanguages(r) {
+		if c, ok := t.messages[lang]; ok {
+			catalogs = append(catalogs, c)
+		}
+		if base, _, ok := strings.Cut(lang, "-"); ok {
+			if c, ok := t.messages[base]; ok {
+				catalogs = append(catalogs, c)
+			}
+		}
+	}
+
+	return catalogs
+}
+
+// acceptedLanguages returns the lowercased language tags of the Accept-Language header of r, by decreasing quality.
+func acceptedLanguages(r *http.Request) []string {
+	type language struct {
+		tag string
+		q   float64
+	}
+
+	var languages []language
+	for _, header := range r.Header.Values("Accept-Language") {
+		for _, part := range strings.Split(header, ",") {
+			tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+			tag = strings.ToLower(strings.TrimSpace(tag))
+			if tag == "" || tag == "*" {
+				continue
+			}
+
+			q := 1.0
+			if k, v, ok := strings.Cut(strings.TrimSpace(params), "="); ok && strings.TrimSpace(k) == "q" {
+				if f, err := strconv.ParseFloat(strings.TrimSpace(v), 64); err == nil {
+					q = f
+				}
+			}
+
+			if q > 0 {
+				languages = append(languages, language{tag: tag, q: q})
+			}
+		}
+	}
+
+	sort.SliceStable(languages, func(i, j int) bool { return languages[i].q > languages[j].q })
+
+	tags := make([]string, len(languages))
+	for i, l := range languages {
+		tags[i] = l.tag
+	}
+
+	return tags
+}
+
+// localize returns the message of err translated by the first of catalogs which has it. Lists of errors are summed up
+// even when wrapped, as ErrorJSON always did, but a single JSONError, FieldError or ElementError is only translated when
+// it is err itself, so the context added by wrapping it is kept.
+func localize(catalogs []MessageCatalog, err error) string {
+	var validationErrors ValidationErrors
+	var jsonErrors JSONErrors
+
+	if errors.As(err, &validationErrors) {
+		return translate(catalogs, "invalid_fields", nil, "body contains invalid fields")
+	}
+	if errors.As(err, &jsonErrors) {
+		return translate(catalogs, "type_errors", nil, "body contains fields of the wrong type")
+	}
+
+	switch e := err.(type) {
+	case *ElementError:
+		msg := localize(catalogs, e.Err)
+		return translate(catalogs, "element", map[string]interface{}{"index": e.Index, "error": msg}, fmt.Sprintf("element %d: %s", e.Index, msg))
+	case ElementErrors:
+		msgs := make([]string, len(e))
+		for i, elementErr := range e {
+			msgs[i] = localize(catalogs, elementErr)
+		}
+		return strings.Join(msgs, "; ")
+	case *JSONError:
+		return translate(catalogs, e.Key, e.params(), e.Error())
+	case FieldError:
+		params := map[string]interface{}{"field": e.Field, "param": e.Param}
+		return e.Field + " " + translate(catalogs, "validate."+e.Rule, params, e.Message)
+	default:
+		return err.Error()
+	}
+}
+
+// translate expands the template of key from the first of catalogs which has it, or returns fallback.
+func translate(catalogs []MessageCatalog, key string, params map[string]interface{}, fallback string) string {
+	if key == "" {
+		return fallback
+	}
+
+	for _, catalog := range catalogs {
+		if template, ok := catalog[key]; ok {
+			return expandMessage(template, params)
+		}
+	}
+
+	return fallback
+}
+
+// expandMessage replaces the {name} placeholders of template with their value in params.
+func expandMessage(template string, params map[string]interface{}) string {
+	pairs := make([]string, 0, 2*len(params))
+	for k, v := range params {
+		pairs = append(pairs, "{"+k+"}", fmt.Sprint(v))
+	}
+
+	return strings.NewReplacer(pairs...).Replace(template)
+}
+
+// params returns the parameters of the message of e, including its location.
+func (e *JSONError) params() map[string]interface{} {
+	params := map[string]interface{}{"field": e.Field, "offset": e.Offset, "line": e.Line, "column": e.Column}
+	for k, v := range e.Params {
+		params[k] = v
+	}
+
+	return params
+}
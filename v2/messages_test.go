@@ -0,0 +1,333 @@
+package toolkit
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+var defaultMessageTests = []struct {
+	name  string
+	json  string
+	tools Tools
+}{
+	{name: "syntax", json: `{"foo": }`},
+	{name: "unexpected eof", json: `{"foo": "bar"`},
+	{name: "type", json: `{"foo": 1}`},
+	{name: "empty", json: ``},
+	{name: "unknown field", json: `{"bar": 1}`},
+	{name: "multiple values", json: `{"foo": "a"}{"foo": "b"}`},
+	{name: "too large", json: `{"foo": "bar"}`, tools: Tools{MaxJsonSize: 5}},
+	{name: "duplicate key", json: `{"foo": "a", "foo": "b"}`, tools: Tools{RejectDuplicateKeys: true}},
+	{name: "too deep", json: `{"foo": [[1]]}`, tools: Tools{MaxJSONDepth: 2, AllowUnknownFields: true}},
+}
+
+func TestDefaultMessages(t *testing.T) {
+	for _, e := range defaultMessageTests {
+		var decoded struct {
+			Foo string `json:"foo"`
+		}
+		req, _ := http.NewRequest("POST", "/", strings.NewReader(e.json))
+		err := e.tools.ReadJSON(httptest.NewRecorder(), req, &decoded)
+
+		var jsonErr *JSONError
+		if !errors.As(err, &jsonErr) || jsonErr.Key == "" {
+			t.Errorf("%s: expected a JSONError with a message key but got %v", e.name, err)
+			continue
+		}
+
+		template, ok := DefaultMessages[jsonErr.Key]
+		if !ok {
+			t.Errorf("%s: key %s is missing from DefaultMessages", e.name, jsonErr.Key)
+			continue
+		}
+
+		if msg := expandMessage(template, jsonErr.params()); msg != jsonErr.Error() {
+			t.Errorf("%s: default message %q does not match %q", e.name, msg, jsonErr.Error())
+		}
+	}
+}
+
+var localizeTests = []struct {
+	name           string
+	acceptLanguage string
+	want           string
+}{
+	{name: "exact language", acceptLanguage: "de", want: `Unbekannter Schlüssel "bar"`},
+	{name: "base language", acceptLanguage: "de-AT", want: `Unbekannter Schlüssel "bar"`},
+	{name: "preferred language", acceptLanguage: "fr, es;q=0.9, de;q=0.8", want: `Clave desconocida "bar"`},
+	{name: "quality order", acceptLanguage: "de;q=0.5, es;q=0.7", want: `Clave desconocida "bar"`},
+	{name: "refused language", acceptLanguage: "de;q=0, fr", want: `body contains unknown key "bar"`},
+	{name: "no header", want: `body contains unknown key "bar"`},
+}
+
+func TestTools_LocalizeError(t *testing.T) {
+	var testTools Tools
+	testTools.RegisterMessages("de", MessageCatalog{"unknown_field": `Unbekannter Schlüssel "{field}"`})
+	testTools.RegisterMessages("es", MessageCatalog{"unknown_field": `Clave desconocida "{field}"`})
+
+	for _, e := range localizeTests {
+		var decoded struct {
+			Foo string `json:"foo"`
+		}
+		req, _ := http.NewRequest("POST", "/", strings.NewReader(`{"bar": 1}`))
+		if e.acceptLanguage != "" {
+			req.Header.Set("Accept-Language", e.acceptLanguage)
+		}
+
+		err := testTools.ReadJSON(httptest.NewRecorder(), req, &decoded)
+		if msg := testTools.LocalizeError(req, err); msg != e.want {
+			t.Errorf("%s: expected %q but got %q", e.name, e.want, msg)
+		}
+	}
+}
+
+func TestTools_LocalizeWrappedError(t *testing.T) {
+	var testTools Tools
+	testTools.RegisterMessages("de", MessageCatalog{"unknown_field": `Unbekannter Schlüssel "{field}"`})
+
+	req, _ := http.NewRequest("POST", "/", nil)
+	req.Header.Set("Accept-Language", "de")
+
+	jsonErr := &JSONError{Kind: JSONErrUnknownField, Field: "bar", Key: "unknown_field", msg: `body contains unknown key "bar"`}
+
+	err := fmt.Errorf("loading user 7: %w", jsonErr)
+	if msg := testTools.LocalizeError(req, err); msg != err.Error() {
+		t.Errorf("expected %q but got %q", err.Error(), msg)
+	}
+}
+
+func TestTools_LocalizeElementError(t *testing.T) {
+	var testTools Tools
+	testTools.RegisterMessages("de", MessageCatalog{"unknown_field": `Unbekannter Schlüssel "{field}"`})
+
+	req, _ := http.NewRequest("POST", "/", nil)
+	req.Header.Set("Accept-Language", "de")
+
+	jsonErr := &JSONError{Kind: JSONErrUnknownField, Field: "bar", Key: "unknown_field", msg: `body contains unknown key "bar"`}
+	err := ElementErrors{{Index: 3, Err: jsonErr}, {Index: 5, Err: jsonErr}}
+
+	want := `element 3: Unbekannter Schlüssel "bar"; element 5: Unbekannter Schlüssel "bar"`
+	if msg := testTools.LocalizeError(req, err); msg != want {
+		t.Errorf("expected %q but got %q", want, msg)
+	}
+
+	testTools.RegisterMessages("de", MessageCatalog{
+		"unknown_field": `Unbekannter Schlüssel "{field}"`,
+		"element":       "Element {index}: {error}",
+	})
+	want = `Element 3: Unbekannter Schlüssel "bar"`
+	if msg := testTools.LocalizeError(req, err[0]); msg != want {
+		t.Errorf("expected %q but got %q", want, msg)
+	}
+}
+
+type bindMessageTarget struct {
+	Page int   `query:"page" form:"page" path:"page"`
+	IDs  []int `query:"id"`
+}
+
+var otherMessageTests = []struct {
+	name string
+	err  func(testTools *Tools) error
+}{
+	{name: "query type", err: func(testTools *Tools) error {
+		return testTools.ReadQuery(httptest.NewRequest("GET", "/?page=x", nil), &bindMessageTarget{})
+	}},
+	{name: "query type list", err: func(testTools *Tools) error {
+		return testTools.ReadQuery(httptest.NewRequest("GET", "/?id=1,x", nil), &bindMessageTarget{})
+	}},
+	{name: "form type", err: func(testTools *Tools) error {
+		req := httptest.NewRequest("POST", "/", strings.NewReader("page=x"))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		return testTools.ReadForm(httptest.NewRecorder(), req, &bindMessageTarget{})
+	}},
+	{name: "bad form", err: func(testTools *Tools) error {
+		req := httptest.NewRequest("POST", "/", strings.NewReader("page=%zz"))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		return testTools.ReadForm(httptest.NewRecorder(), req, &bindMessageTarget{})
+	}},
+	{name: "invalid content type", err: func(testTools *Tools) error {
+		req := httptest.NewRequest("POST", "/", strings.NewReader("x"))
+		req.Header.Set("Content-Type", "text/;;")
+		return testTools.ReadBody(httptest.NewRecorder(), req, &bindMessageTarget{})
+	}},
+	{name: "unsupported content type", err: func(testTools *Tools) error {
+		req := httptest.NewRequest("POST", "/", strings.NewReader("x"))
+		req.Header.Set("Content-Type", "text/plain")
+		return testTools.ReadBody(httptest.NewRecorder(), req, &bindMessageTarget{})
+	}},
+	{name: "bad body", err: func(testTools *Tools) error {
+		testTools.RegisterCodec("application/yaml", func(raw []byte, data interface{}) error { return errors.New("bad yaml") })
+		req := httptest.NewRequest("POST", "/", strings.NewReader("x"))
+		req.Header.Set("Content-Type", "application/yaml")
+		return testTools.ReadBody(httptest.NewRecorder(), req, &bindMessageTarget{})
+	}},
+	{name: "xml syntax", err: func(testTools *Tools) error {
+		req := httptest.NewRequest("POST", "/", strings.NewReader("<a>\n<b></a>"))
+		req.Header.Set("Content-Type", "application/xml")
+		return testTools.ReadBody(httptest.NewRecorder(), req, &bindMessageTarget{})
+	}},
+	{name: "xml type", err: func(testTools *Tools) error {
+		req := httptest.NewRequest("POST", "/", strings.NewReader("<a><page>x</page></a>"))
+		req.Header.Set("Content-Type", "application/xml")
+		return testTools.ReadBody(httptest.NewRecorder(), req, &struct {
+			Page int `xml:"page"`
+		}{})
+	}},
+	{name: "merge patch not json", err: func(testTools *Tools) error {
+		_, _, err := testTools.ApplyMergePatch([]byte(`{}`), []byte(`{`))
+		return err
+	}},
+	{name: "not operations", err: func(testTools *Tools) error {
+		_, _, err := testTools.ApplyJSONPatch([]byte(`{}`), []byte(`{}`))
+		return err
+	}},
+	{name: "missing path", err: func(testTools *Tools) error {
+		_, _, err := testTools.ApplyJSONPatch([]byte(`{}`), []byte(`[{"op": "remove"}]`))
+		return err
+	}},
+	{name: "unknown operation", err: func(testTools *Tools) error {
+		_, _, err := testTools.ApplyJSONPatch([]byte(`{}`), []byte(`[{"op": "merge", "path": ""}]`))
+		return err
+	}},
+	{name: "invalid pointer", err: func(testTools *Tools) error {
+		_, _, err := testTools.ApplyJSONPatch([]byte(`{}`), []byte(`[{"op": "remove", "path": "a"}]`))
+		return err
+	}},
+	{name: "invalid index", err: func(testTools *Tools) error {
+		_, _, err := testTools.ApplyJSONPatch([]byte(`{"a": []}`), []byte(`[{"op": "remove", "path": "/a/x"}]`))
+		return err
+	}},
+	{name: "index out of bounds", err: func(testTools *Tools) error {
+		_, _, err := testTools.ApplyJSONPatch([]byte(`{"a": []}`), []byte(`[{"op": "add", "path": "/a/3", "value": 1}]`))
+		return err
+	}},
+	{name: "path not found", err: func(testTools *Tools) error {
+		_, _, err := testTools.ApplyJSONPatch([]byte(`{}`), []byte(`[{"op": "remove", "path": "/a"}]`))
+		return err
+	}},
+	{name: "test failed", err: func(testTools *Tools) error {
+		_, _, err := testTools.ApplyJSONPatch([]byte(`{"a": 1}`), []byte(`[{"op": "test", "path": "/a", "value": 2}]`))
+		return err
+	}},
+}
+
+func TestDefaultMessagesOtherErrors(t *testing.T) {
+	for _, e := range otherMessageTests {
+		var testTools Tools
+		err := e.err(&testTools)
+
+		var jsonErr *JSONError
+		if !errors.As(err, &jsonErr) || jsonErr.Key == "" {
+			t.Errorf("%s: expected a JSONError with a message key but got %v", e.name, err)
+			continue
+		}
+
+		template, ok := DefaultMessages[jsonErr.Key]
+		if !ok {
+			t.Errorf("%s: key %s is missing from DefaultMessages", e.name, jsonErr.Key)
+			continue
+		}
+
+		if msg := expandMessage(template, jsonErr.params()); msg != jsonErr.Error() {
+			t.Errorf("%s: default message %q does not match %q", e.name, msg, jsonErr.Error())
+		}
+	}
+}
+
+func TestTools_LocalizedErrorJSON(t *testing.T) {
+	var testTools Tools
+	testTools.RegisterMessages("de", MessageCatalog{
+		"invalid_fields":    "ungültige Felder",
+		"validate.required": "ist erforderlich",
+	})
+
+	req, _ := http.NewRequest("POST", "/", nil)
+	req.Header.Set("Accept-Language", "de-DE,de;q=0.9,en;q=0.8")
+
+	err := ValidationErrors{
+		{Field: "name", Rule: "required", Message: "is required"},
+		{Field: "qty", Rule: "min", Param: "1", Message: "must be at least 1"},
+	}
+
+	rr := httptest.NewRecorder()
+	_ = testTools.LocalizedErrorJSON(rr, req, err)
+
+	var payload struct {
+		Message string            `json:"message"`
+		Data    map[string]string `json:"data"`
+	}
+	if err := json.NewDecoder(rr.Body).Decode(&payload); err != nil {
+		t.Fatal(err)
+	}
+
+	if rr.Code != http.StatusUnprocessableEntity {
+		t.Errorf("wrong status code %d", rr.Code)
+	}
+
+	if payload.Message != "ungültige Felder" || payload.Data["name"] != "ist erforderlich" || payload.Data["qty"] != "must be at least 1" {
+		t.Errorf("wrong payload %+v", payload)
+	}
+}
+
+type typeErrorsItem struct {
+	Name  string `json:"name"`
+	Qty   int    `json:"qty"`
+	Lines []struct {
+		Price float64 `json:"price"`
+	} `json:"lines"`
+	Tags  map[string]bool `json:"tags"`
+	Count uint8           `json:"count"`
+}
+
+func TestTools_ReadJSONCollectTypeErrors(t *testing.T) {
+	body := `{"name": 1, "QTY": "2", "lines": [{"price": 1}, {"price": "x"}], "tags": {"a": true, "b": "no"}, "count": 300}`
+
+	var testTools Tools
+	testTools.CollectTypeErrors = true
+
+	var decoded typeErrorsItem
+	req, _ := http.NewRequest("POST", "/", strings.NewReader(body))
+	err := testTools.ReadJSON(httptest.NewRecorder(), req, &decoded)
+
+	var errs JSONErrors
+	if !errors.As(err, &errs) {
+		t.Fatalf("expected JSONErrors but got %v", err)
+	}
+
+	want := []string{"count", "lines[1].price", "name", "qty", "tags.b"}
+	if len(errs) != len(want) {
+		t.Fatalf("expected %d errors but got %v", len(want), errs)
+	}
+	for i, e := range errs {
+		if e.Field != want[i] || e.Kind != JSONErrType {
+			t.Errorf("expected a type error on %s but got %v", want[i], e)
+		}
+	}
+
+	var jsonErr *JSONError
+	if !errors.As(err, &jsonErr) || jsonErr.Field != "count" {
+		t.Errorf("expected the first error to be reachable as a JSONError, got %v", jsonErr)
+	}
+
+	rr := httptest.NewRecorder()
+	_ = testTools.ErrorJSON(rr, err)
+
+	var payload JSONResponse
+	_ = json.NewDecoder(rr.Body).Decode(&payload)
+	if rr.Code != http.StatusBadRequest || len(payload.Data.(map[string]interface{})) != len(want) {
+		t.Errorf("wrong response %d %+v", rr.Code, payload)
+	}
+
+	testTools.CollectTypeErrors = false
+	req, _ = http.NewRequest("POST", "/", strings.NewReader(body))
+	err = testTools.ReadJSON(httptest.NewRecorder(), req, &decoded)
+	if errors.As(err, &errs) {
+		t.Error("type errors collected while CollectTypeErrors is off")
+	}
+}
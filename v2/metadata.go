@@ -0,0 +1,168 @@
+package toolkit
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MetadataMode decides whether, and how, metadata for uploaded files is persisted.
+type MetadataMode int
+
+const (
+	// MetadataNone does not persist any metadata. This is the default.
+	MetadataNone MetadataMode = iota
+	// MetadataSidecar writes a JSON file named <file>.meta.json next to every uploaded file.
+	MetadataSidecar
+	// MetadataManifest keeps the metadata of every file of a directory in a single manifest file.
+	MetadataManifest
+)
+
+const (
+	// sidecarSuffix is appended to the name of an uploaded file to get the name of its sidecar.
+	sidecarSuffix = ".meta.json"
+	// manifestFileName is the name of the per-directory manifest file.
+	manifestFileName = ".manifest.json"
+)
+
+// manifestMu serializes the read-modify-write cycle on manifest files.
+var manifestMu sync.Mutex
+
+// FileMetadata is the information persisted about an uploaded file.
+type FileMetadata struct {
+	NewFileName      string            `json:"new_file_name"`
+	OriginalFileName string            `json:"original_file_name"`
+	ContentType      string            `json:"content_type"`
+	FileSize         int64             `json:"file_size"`
+	SHA256           string            `json:"sha256"`
+	Fields           map[string]string `json:"fields,omitempty"`
+	UploadedAt       time.Time         `json:"uploaded_at"`
+}
+
+// IsMetadataFile reports whether name is a sidecar or manifest file written by the toolkit.
+func IsMetadataFile(name string) bool {
+	return name == manifestFileName || strings.HasSuffix(name, sidecarSuffix)
+}
+
+// ReadFileMetadata returns the metadata stored for fileName in dir, looking at the sidecar first and the manifest second.
+func (t *Tools) ReadFileMetadata(dir, fileName string) (*FileMetadata, error) {
+	var meta FileMetadata
+
+	err := readJSONFile(filepath.Join(dir, fileName+sidecarSuffix), &meta)
+	if err == nil {
+		return &meta, nil
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+
+	manifest, err := t.ReadManifest(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	m, ok := manifest[fileName]
+	if !ok {
+		return nil, os.ErrNotExist
+	}
+
+	return m, nil
+}
+
+// ReadManifest returns all the metadata stored in the manifest of dir, keyed by the stored file name.
+func (t *Tools) ReadManifest(dir string) (map[string]*FileMetadata, error) {
+	manifest := make(map[string]*FileMetadata)
+
+	err := readJSONFile(filepath.Join(dir, manifestFileName), &manifest)
+	if err != nil {
+		return nil, err
+	}
+
+	return manifest, nil
+}
+
+// saveMetadata persists the metadata of an uploaded file according to t.MetadataMode.
+func (t *Tools) saveMetadata(dir string, file *UploadedFile, values map[string][]string) error {
+	if t.MetadataMode == MetadataNone {
+		return nil
+	}
+
+	meta := &FileMetadata{
+		NewFileName:      file.NewFileName,
+		OriginalFileName: file.OriginalFileName,
+		ContentType:      file.ContentType,
+		FileSize:         file.FileSize,
+		SHA256:           file.SHA256,
+		UploadedAt:       time.Now().UTC(),
+	}
+
+	for _, key := range t.MetadataFields {
+		if v, ok := values[key]; ok && len(v) > 0 {
+			if meta.Fields == nil {
+				meta.Fields = make(map[string]string)
+			}
+			meta.Fields[key] = v[0]
+		}
+	}
+
+	switch t.MetadataMode {
+	case MetadataSidecar:
+		return writeJSONFile(filepath.Join(dir, file.NewFileName+sidecarSuffix), meta)
+
+	case MetadataManifest:
+		manifestMu.Lock()
+		defer manifestMu.Unlock()
+
+		manifest, err := t.ReadManifest(dir)
+		if err != nil {
+			if !errors.Is(err, os.ErrNotExist) {
+				return err
+			}
+			manifest = make(map[string]*FileMetadata)
+		}
+		manifest[file.NewFileName] = meta
+
+		return writeJSONFile(filepath.Join(dir, manifestFileName), manifest)
+
+	default:
+		return errors.New("unknown metadata mode")
+	}
+}
+
+// readJSONFile decodes the JSON file at name into data.
+func readJSONFile(name string, data interface{}) error {
+	b, err := os.ReadFile(name)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(b, data)
+}
+
+// writeJSONFile atomically replaces the file at name with the JSON encoding of data.
+func writeJSONFile(name string, data interface{}) error {
+	out, err := json.MarshalIndent(data, "", "\t")
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(name), filepath.Base(name)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err = tmp.Write(out); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), name)
+}
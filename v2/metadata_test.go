@@ -0,0 +1,176 @@
+package toolkit
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// newUploadRequest builds a multipart request uploading the file at name along with the given form values.
+func newUploadRequest(t *testing.T, name string, values map[string]string) *http.Request {
+	t.Helper()
+
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+
+	for k, v := range values {
+		if err := writer.WriteField(k, v); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	part, err := writer.CreateFormFile("file", filepath.Base(name))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := os.Open(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	if _, err = io.Copy(part, f); err != nil {
+		t.Fatal(err)
+	}
+	writer.Close()
+
+	request := httptest.NewRequest("POST", "/", body)
+	request.Header.Add("Content-Type", writer.FormDataContentType())
+
+	return request
+}
+
+var metadataTests = []struct {
+	name string
+	mode MetadataMode
+	file string
+}{
+	{name: "sidecar", mode: MetadataSidecar, file: "img.jpg.meta.json"},
+	{name: "manifest", mode: MetadataManifest, file: ".manifest.json"},
+}
+
+func TestTools_UploadMetadata(t *testing.T) {
+	for _, e := range metadataTests {
+		dir := t.TempDir()
+
+		var testTools Tools
+		testTools.MetadataMode = e.mode
+		testTools.MetadataFields = []string{"title"}
+
+		request := newUploadRequest(t, "./testdata/img.jpg", map[string]string{"title": "a cat", "secret": "x"})
+		uploaded, err := testTools.UploadOneFile(request, dir, false)
+		if err != nil {
+			t.Fatalf("%s: %s", e.name, err)
+		}
+
+		if _, err := os.Stat(filepath.Join(dir, e.file)); err != nil {
+			t.Errorf("%s: expected metadata file %s to exist", e.name, e.file)
+		}
+
+		meta, err := testTools.ReadFileMetadata(dir, uploaded.NewFileName)
+		if err != nil {
+			t.Fatalf("%s: %s", e.name, err)
+		}
+
+		if meta.OriginalFileName != "img.jpg" || meta.ContentType != "image/jpeg" {
+			t.Errorf("%s: wrong metadata: %+v", e.name, meta)
+		}
+
+		if meta.SHA256 != uploaded.SHA256 || meta.FileSize != uploaded.FileSize || len(meta.SHA256) != 64 {
+			t.Errorf("%s: metadata does not match uploaded file: %+v", e.name, meta)
+		}
+
+		if meta.Fields["title"] != "a cat" {
+			t.Errorf("%s: expected title field to be recorded", e.name)
+		}
+
+		if _, ok := meta.Fields["secret"]; ok {
+			t.Errorf("%s: field not in MetadataFields was recorded", e.name)
+		}
+	}
+}
+
+func TestTools_UploadReservedName(t *testing.T) {
+	for _, name := range []string{".manifest.json", "img.jpg.meta.json", "IMG.JPG.META.JSON"} {
+		src := filepath.Join(t.TempDir(), name)
+		if err := os.WriteFile(src, []byte(`{"forged": true}`), 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		var testTools Tools
+		testTools.MetadataMode = MetadataManifest
+
+		dir := t.TempDir()
+		if _, err := testTools.UploadOneFile(newUploadRequest(t, src, nil), dir, false); err == nil {
+			t.Errorf("%s: expected the upload to be refused", name)
+		}
+
+		if _, err := os.Stat(filepath.Join(dir, name)); !errors.Is(err, os.ErrNotExist) {
+			t.Errorf("%s: reserved file was written", name)
+		}
+
+		//	renamed uploads never clash with metadata files
+		if _, err := testTools.UploadOneFile(newUploadRequest(t, src, nil), dir); err != nil {
+			t.Errorf("%s: renamed upload failed: %s", name, err)
+		}
+	}
+}
+
+func TestTools_UploadSmallTextFile(t *testing.T) {
+	src := filepath.Join(t.TempDir(), "notes.txt")
+	if err := os.WriteFile(src, []byte("remember the cat food\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var testTools Tools
+	testTools.MetadataMode = MetadataSidecar
+
+	dir := t.TempDir()
+	uploaded, err := testTools.UploadOneFile(newUploadRequest(t, src, nil), dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if uploaded.ContentType != "text/plain; charset=utf-8" {
+		t.Errorf("wrong content type %s", uploaded.ContentType)
+	}
+
+	rr := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+	testTools.DownloadStaticFile(rr, req, filepath.Join(dir, uploaded.NewFileName), "")
+
+	if rr.Header().Get("Content-Type") != "text/plain; charset=utf-8" {
+		t.Errorf("wrong content type on download %s", rr.Header().Get("Content-Type"))
+	}
+}
+
+func TestTools_DownloadStaticFileRestoresMetadata(t *testing.T) {
+	dir := t.TempDir()
+
+	var testTools Tools
+	testTools.MetadataMode = MetadataSidecar
+
+	uploaded, err := testTools.UploadOneFile(newUploadRequest(t, "./testdata/img.jpg", nil), dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rr := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+	testTools.DownloadStaticFile(rr, req, filepath.Join(dir, uploaded.NewFileName), "")
+
+	if rr.Header().Get("Content-Disposition") != "attachment; filename=\"img.jpg\"" {
+		t.Errorf("original file name not restored, got %s", rr.Header().Get("Content-Disposition"))
+	}
+
+	if rr.Header().Get("Content-Type") != "image/jpeg" {
+		t.Errorf("content type not restored, got %s", rr.Header().Get("Content-Type"))
+	}
+}
@@ -0,0 +1,71 @@
+package toolkit
+
+import (
+	"mime"
+	"net/http"
+	"net/url"
+	"path"
+	"path/filepath"
+)
+
+// OffloadMode decides whether downloads of files on disk are handed over to the web server in front of the application.
+type OffloadMode int
+
+const (
+	// OffloadNone serves files from Go. This is the default.
+	OffloadNone OffloadMode = iota
+	// OffloadXAccelRedirect sets the X-Accel-Redirect header understood by nginx. The path of the file relative to
+	// Tools.OffloadRoot is appended to Tools.OffloadPrefix, which should be an internal location of nginx.
+	OffloadXAccelRedirect
+	// OffloadXSendfile sets the X-Sendfile header understood by Apache and lighttpd to the absolute path of the file. When
+	// Tools.OffloadPrefix is set, it replaces Tools.OffloadRoot in that path.
+	OffloadXSendfile
+)
+
+// offload hands the download of the file at pathName over to the web server according to t.OffloadMode, and reports whether
+// it did. Files outside of t.OffloadRoot are not offloaded and must be served directly.
+func (t *Tools) offload(w http.ResponseWriter, pathName string) bool {
+	if t.OffloadMode == OffloadNone {
+		return false
+	}
+
+	abs, err := filepath.Abs(pathName)
+	if err != nil {
+		return false
+	}
+
+	root, err := filepath.Abs(t.OffloadRoot)
+	if err != nil {
+		return false
+	}
+
+	rel, err := filepath.Rel(root, abs)
+	if err != nil || !filepath.IsLocal(rel) {
+		return false
+	}
+
+	switch t.OffloadMode {
+	case OffloadXAccelRedirect:
+		u := url.URL{Path: path.Join("/", t.OffloadPrefix, filepath.ToSlash(rel))}
+		w.Header().Set("X-Accel-Redirect", u.EscapedPath())
+
+	case OffloadXSendfile:
+		target := abs
+		if t.OffloadPrefix != "" {
+			target = filepath.Join(t.OffloadPrefix, rel)
+		}
+		w.Header().Set("X-Sendfile", target)
+
+	default:
+		return false
+	}
+
+	if w.Header().Get("Content-Type") == "" {
+		if ctype := mime.TypeByExtension(filepath.Ext(pathName)); ctype != "" {
+			w.Header().Set("Content-Type", ctype)
+		}
+	}
+	w.WriteHeader(http.StatusOK)
+
+	return true
+}
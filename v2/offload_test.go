@@ -0,0 +1,69 @@
+package toolkit
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+)
+
+var offloadTests = []struct {
+	name     string
+	mode     OffloadMode
+	prefix   string
+	header   string
+	expected string
+}{
+	{name: "nginx", mode: OffloadXAccelRedirect, prefix: "/protected", header: "X-Accel-Redirect", expected: "/protected/pic.jpg"},
+	{name: "apache", mode: OffloadXSendfile, header: "X-Sendfile", expected: "testdata/pic.jpg"},
+	{name: "apache mapped", mode: OffloadXSendfile, prefix: "/srv/files", header: "X-Sendfile", expected: "/srv/files/pic.jpg"},
+}
+
+func TestTools_DownloadOffload(t *testing.T) {
+	for _, e := range offloadTests {
+		var testTools Tools
+		testTools.OffloadMode = e.mode
+		testTools.OffloadRoot = "./testdata"
+		testTools.OffloadPrefix = e.prefix
+
+		expected := e.expected
+		if e.prefix == "" {
+			expected, _ = filepath.Abs(e.expected)
+		}
+
+		rr := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/", nil)
+		testTools.DownloadFile(rr, req, "./testdata", "pic.jpg", "rowdy-cat.jpg")
+
+		if rr.Header().Get(e.header) != expected {
+			t.Errorf("%s: expected %s to be %s but got %s", e.name, e.header, expected, rr.Header().Get(e.header))
+		}
+
+		if rr.Body.Len() != 0 {
+			t.Errorf("%s: expected an empty body but got %d bytes", e.name, rr.Body.Len())
+		}
+
+		if rr.Header().Get("Content-Disposition") != `attachment; filename="rowdy-cat.jpg"` || rr.Header().Get("Content-Type") != "image/jpeg" {
+			t.Errorf("%s: wrong download headers: %v", e.name, rr.Header())
+		}
+	}
+}
+
+func TestTools_DownloadOffloadFallback(t *testing.T) {
+	var testTools Tools
+	testTools.OffloadMode = OffloadXAccelRedirect
+	testTools.OffloadRoot = "./testdata/uploads"
+	testTools.OffloadPrefix = "/protected"
+
+	rr := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+	testTools.DownloadStaticFile(rr, req, "./testdata/pic.jpg", "rowdy-cat.jpg")
+
+	if rr.Header().Get("X-Accel-Redirect") != "" {
+		t.Error("file outside of the offload root was offloaded")
+	}
+
+	if rr.Body.Len() != 91585 {
+		t.Errorf("expected the file to be served directly, got %d bytes", rr.Body.Len())
+	}
+}
@@ -0,0 +1,583 @@
+package toolkit
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"mime"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	// JSONErrInvalidPatch is a patch document which is malformed, such as an unknown operation or a bad JSON Pointer.
+	JSONErrInvalidPatch JSONErrorKind = "invalid_patch"
+	// JSONErrPatchConflict is a patch which can not be applied to the document, such as a failed test operation or a
+	// path which does not exist.
+	JSONErrPatchConflict JSONErrorKind = "patch_conflict"
+)
+
+// jsonPatchMediaType is the media type of RFC 6902 JSON Patch documents.
+const jsonPatchMediaType = "application/json-patch+json"
+
+// patchOperation is a single operation of a JSON Patch document.
+type patchOperation struct {
+	Op    string          `json:"op"`
+	Path  *string         `json:"path"`
+	From  *string         `json:"from"`
+	Value json.RawMessage `json:"value"`
+}
+
+// ReadPatch reads a patch from the body of r, with the same limits and errors as ReadJSON, and applies it to target, which
+// must be a pointer. Bodies sent as application/json-patch+json are RFC 6902 JSON Patch documents, anything else is read
+// as an RFC 7396 JSON Merge Patch. Default tags are not applied. It returns the JSON Pointers of the values changed by the
+// patch.
+func (t *Tools) ReadPatch(w http.ResponseWriter, r *http.Request, target interface{}) ([]string, error) {
+	v := reflect.ValueOf(target)
+	if v.Kind() != reflect.Pointer || v.IsNil() {
+		return nil, &JSONError{Kind: JSONErrInvalidTarget, msg: "patch target must be a non-nil pointer"}
+	}
+
+	maxBytes := t.maxJSONBytes()
+
+	patch, err := t.readJSONBody(w, r, maxBytes)
+	if err != nil {
+		return nil, err
+	}
+
+	//	a merge patch follows the structure of target, a JSON Patch is a list of operations
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	patchType := v.Type()
+	if mediaType == jsonPatchMediaType {
+		patchType = nil
+	}
+
+	if err = t.checkJSONStructure(patch, patchType); err != nil {
+		return nil, err
+	}
+
+	doc, err := json.Marshal(target)
+	if err != nil {
+		return nil, err
+	}
+
+	var patched []byte
+	var changed []string
+
+	if mediaType == jsonPatchMediaType {
+		patched, changed, err = t.ApplyJSONPatch(doc, patch)
+	} else {
+		patched, changed, err = t.ApplyMergePatch(doc, patch)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	//	decode into a copy whose JSON fields are cleared, or fields removed by the patch would keep their old value, while
+	//	fields JSON does not carry are kept. The copy is only stored once it is known to be good, so a rejected patch
+	//	leaves target untouched. Default tags are not applied, as fields left out by omitempty are missing from the
+	//	patched document even though target set them.
+	result := reflect.New(v.Elem().Type())
+	result.Elem().Set(v.Elem())
+	clearJSONFields(result.Elem())
+	if _, err = t.decodeJSONDocument(patched, result.Interface(), len(patched), false, false); err != nil {
+		return nil, err
+	}
+	v.Elem().Set(result.Elem())
+
+	return changed, nil
+}
+
+// clearJSONFields sets every field of v which is encoded to JSON to its zero value, going into nested structs so that
+// their unexported and `json:"-"` fields are kept. Values which are not structs are cleared whole, and so are pointers,
+// as their target is shared with the original value.
+func clearJSONFields(v reflect.Value) {
+	typ := v.Type()
+	if typ.Kind() != reflect.Struct || typ.Implements(jsonUnmarshalerType) || reflect.PointerTo(typ).Implements(jsonUnmarshalerType) ||
+		reflect.PointerTo(typ).Implements(textUnmarshalerType) {
+		v.Set(reflect.Zero(typ))
+		return
+	}
+
+	for i := 0; i < typ.NumField(); i++ {
+		sf := typ.Field(i)
+		fv := v.Field(i)
+
+		if !fv.CanSet() {
+			//	unexported fields, which JSON never sets
+			continue
+		}
+		if _, ok := jsonFieldName(sf); !ok {
+			continue
+		}
+
+		clearJSONFields(fv)
+	}
+}
+
+// ApplyMergePatch applies the RFC 7396 JSON Merge Patch patch to the JSON document doc. It returns the patched document
+// and the JSON Pointers of the values changed by the patch.
+func (t *Tools) ApplyMergePatch(doc, patch []byte) ([]byte, []string, error) {
+	target, err := decodeJSONValue(doc)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	p, err := decodeJSONValue(patch)
+	if err != nil {
+		return nil, nil, &JSONError{Kind: JSONErrInvalidPatch, Key: "patch_invalid_json", Err: err, msg: "patch is not valid JSON"}
+	}
+
+	var changed []string
+	out, err := json.Marshal(mergePatch(target, p, "", &changed))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return out, changed, nil
+}
+
+// mergePatch merges patch into target as described by RFC 7396, recording the pointers of the changed values.
+func mergePatch(target, patch interface{}, path string, changed *[]string) interface{} {
+	p, ok := patch.(map[string]interface{})
+	if !ok {
+		if !jsonEqual(target, patch) {
+			*changed = append(*changed, path)
+		}
+		return patch
+	}
+
+	t, ok := target.(map[string]interface{})
+	if !ok {
+		if target != nil {
+			*changed = append(*changed, path)
+		}
+		t = make(map[string]interface{})
+	}
+
+	keys := make([]string, 0, len(p))
+	for k := range p {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		child := path + "/" + escapePointerToken(k)
+
+		if p[k] == nil {
+			if _, exists := t[k]; exists {
+				delete(t, k)
+				*changed = append(*changed, child)
+			}
+			continue
+		}
+
+		t[k] = mergePatch(t[k], p[k], child, changed)
+	}
+
+	return t
+}
+
+// ApplyJSONPatch applies the RFC 6902 JSON Patch patch to the JSON document doc. The operations are applied in order and
+// the document is left untouched if one of them fails. It returns the patched document and the JSON Pointers of the
+// values changed by the patch.
+func (t *Tools) ApplyJSONPatch(doc, patch []byte) ([]byte, []string, error) {
+	target, err := decodeJSONValue(doc)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var ops []patchOperation
+	dec := json.NewDecoder(bytes.NewReader(patch))
+	dec.DisallowUnknownFields()
+	if err = dec.Decode(&ops); err != nil {
+		return nil, nil, &JSONError{Kind: JSONErrInvalidPatch, Key: "patch_not_operations", Err: err, msg: "patch must be an array of operations"}
+	}
+
+	var changed []string
+	for i, op := range ops {
+		if target, err = applyOperation(target, op); err != nil {
+			if jsonErr, ok := err.(*JSONError); ok {
+				jsonErr.Params["operation"] = i
+				jsonErr.msg = fmt.Sprintf("operation %d: %s", i, jsonErr.msg)
+			}
+			return nil, nil, err
+		}
+
+		switch op.Op {
+		case "test":
+		case "move":
+			changed = append(changed, *op.From, appendedPath(target, *op.Path))
+		default:
+			changed = append(changed, appendedPath(target, *op.Path))
+		}
+	}
+
+	out, err := json.Marshal(target)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return out, changed, nil
+}
+
+// appendedPath returns path with its final "-", which appends to an array, replaced by the index of the element it
+// added to doc, the document once the operation is applied.
+func appendedPath(doc interface{}, path string) string {
+	parent, ok := strings.CutSuffix(path, "/-")
+	if !ok {
+		return path
+	}
+
+	tokens, err := parsePointer(parent)
+	if err != nil {
+		return path
+	}
+	if arr, err := pointerGet(doc, tokens); err == nil {
+		if a, ok := arr.([]interface{}); ok && len(a) > 0 {
+			return parent + "/" + strconv.Itoa(len(a)-1)
+		}
+	}
+
+	return path
+}
+
+// applyOperation applies a single JSON Patch operation to doc and returns the new document.
+func applyOperation(doc interface{}, op patchOperation) (interface{}, error) {
+	if op.Path == nil {
+		return nil, invalidPatch("patch_missing_path", nil, "missing path")
+	}
+	path, err := parsePointer(*op.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	var from []string
+	if op.Op == "move" || op.Op == "copy" {
+		if op.From == nil {
+			return nil, invalidPatch("patch_missing_from", nil, "missing from")
+		}
+		if from, err = parsePointer(*op.From); err != nil {
+			return nil, err
+		}
+	}
+
+	var value interface{}
+	if op.Op == "add" || op.Op == "replace" || op.Op == "test" {
+		//	a null value is kept as the raw null, so only a missing value is empty
+		if len(op.Value) == 0 {
+			return nil, invalidPatch("patch_missing_value", nil, "missing value")
+		}
+		if value, err = decodeJSONValue(op.Value); err != nil {
+			return nil, invalidPatch("patch_invalid_value", nil, "invalid value")
+		}
+	}
+
+	switch op.Op {
+	case "add":
+		return pointerAdd(doc, path, value)
+
+	case "remove":
+		doc, _, err = pointerRemove(doc, path)
+		return doc, err
+
+	case "replace":
+		if _, err = pointerGet(doc, path); err != nil {
+			return nil, err
+		}
+		if len(path) == 0 {
+			return value, nil
+		}
+		doc, _, err = pointerRemove(doc, path)
+		if err != nil {
+			return nil, err
+		}
+		return pointerAdd(doc, path, value)
+
+	case "move":
+		if *op.Path != *op.From && strings.HasPrefix(*op.Path, *op.From+"/") {
+			return nil, invalidPatch("patch_move_into_itself", nil, "a value can not be moved into itself")
+		}
+		doc, value, err = pointerRemove(doc, from)
+		if err != nil {
+			return nil, err
+		}
+		return pointerAdd(doc, path, value)
+
+	case "copy":
+		if value, err = pointerGet(doc, from); err != nil {
+			return nil, err
+		}
+		//	copy the value, so later operations on one copy do not change the other
+		out, _ := json.Marshal(value)
+		value, _ = decodeJSONValue(out)
+		return pointerAdd(doc, path, value)
+
+	case "test":
+		current, err := pointerGet(doc, path)
+		if err != nil {
+			return nil, err
+		}
+		if !jsonEqual(current, value) {
+			return nil, patchConflict("patch_test_failed", map[string]interface{}{"path": *op.Path}, fmt.Sprintf("test failed at %s", *op.Path))
+		}
+		return doc, nil
+
+	default:
+		return nil, invalidPatch("patch_unknown_operation", map[string]interface{}{"op": op.Op}, fmt.Sprintf("unknown operation %q", op.Op))
+	}
+}
+
+// pointerGet returns the value of doc at path.
+func pointerGet(doc interface{}, path []string) (interface{}, error) {
+	for _, token := range path {
+		switch c := doc.(type) {
+		case map[string]interface{}:
+			v, ok := c[token]
+			if !ok {
+				return nil, missingPath(path)
+			}
+			doc = v
+		case []interface{}:
+			i, err := arrayIndex(token, len(c)-1)
+			if err != nil {
+				return nil, err
+			}
+			doc = c[i]
+		default:
+			return nil, missingPath(path)
+		}
+	}
+
+	return doc, nil
+}
+
+// pointerAdd adds value to doc at path, inserting it when the parent is an array, and returns the new document.
+func pointerAdd(doc interface{}, path []string, value interface{}) (interface{}, error) {
+	if len(path) == 0 {
+		return value, nil
+	}
+
+	token, rest := path[0], path[1:]
+
+	switch c := doc.(type) {
+	case map[string]interface{}:
+		if len(rest) == 0 {
+			c[token] = value
+			return c, nil
+		}
+		child, ok := c[token]
+		if !ok {
+			return nil, missingPath(path)
+		}
+		v, err := pointerAdd(child, rest, value)
+		if err != nil {
+			return nil, err
+		}
+		c[token] = v
+		return c, nil
+
+	case []interface{}:
+		if len(rest) == 0 {
+			i := len(c)
+			if token != "-" {
+				var err error
+				if i, err = arrayIndex(token, len(c)); err != nil {
+					return nil, err
+				}
+			}
+			c = append(c, nil)
+			copy(c[i+1:], c[i:])
+			c[i] = value
+			return c, nil
+		}
+		i, err := arrayIndex(token, len(c)-1)
+		if err != nil {
+			return nil, err
+		}
+		if c[i], err = pointerAdd(c[i], rest, value); err != nil {
+			return nil, err
+		}
+		return c, nil
+
+	default:
+		return nil, missingPath(path)
+	}
+}
+
+// pointerRemove removes the value of doc at path, and returns the new document along with the removed value.
+func pointerRemove(doc interface{}, path []string) (interface{}, interface{}, error) {
+	if len(path) == 0 {
+		return nil, doc, nil
+	}
+
+	token, rest := path[0], path[1:]
+
+	switch c := doc.(type) {
+	case map[string]interface{}:
+		child, ok := c[token]
+		if !ok {
+			return nil, nil, missingPath(path)
+		}
+		if len(rest) == 0 {
+			delete(c, token)
+			return c, child, nil
+		}
+		v, removed, err := pointerRemove(child, rest)
+		if err != nil {
+			return nil, nil, err
+		}
+		c[token] = v
+		return c, removed, nil
+
+	case []interface{}:
+		i, err := arrayIndex(token, len(c)-1)
+		if err != nil {
+			return nil, nil, err
+		}
+		if len(rest) == 0 {
+			removed := c[i]
+			return append(c[:i], c[i+1:]...), removed, nil
+		}
+		v, removed, err := pointerRemove(c[i], rest)
+		if err != nil {
+			return nil, nil, err
+		}
+		c[i] = v
+		return c, removed, nil
+
+	default:
+		return nil, nil, missingPath(path)
+	}
+}
+
+// parsePointer splits an RFC 6901 JSON Pointer into its unescaped reference tokens.
+func parsePointer(pointer string) ([]string, error) {
+	if pointer == "" {
+		return nil, nil
+	}
+	if !strings.HasPrefix(pointer, "/") {
+		return nil, invalidPatch("patch_invalid_pointer", map[string]interface{}{"pointer": pointer}, fmt.Sprintf("invalid JSON Pointer %q", pointer))
+	}
+
+	tokens := strings.Split(pointer[1:], "/")
+	for i, token := range tokens {
+		tokens[i] = strings.ReplaceAll(strings.ReplaceAll(token, "~1", "/"), "~0", "~")
+	}
+
+	return tokens, nil
+}
+
+// formatPointer joins reference tokens back into a JSON Pointer.
+func formatPointer(tokens []string) string {
+	var b strings.Builder
+	for _, token := range tokens {
+		b.WriteString("/" + escapePointerToken(token))
+	}
+
+	return b.String()
+}
+
+// escapePointerToken escapes a key to be used as a JSON Pointer reference token.
+func escapePointerToken(token string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(token, "~", "~0"), "/", "~1")
+}
+
+// arrayIndex parses an array index of a JSON Pointer, which must not be greater than max.
+func arrayIndex(token string, max int) (int, error) {
+	i, err := strconv.Atoi(token)
+	if err != nil || i < 0 || (len(token) > 1 && token[0] == '0') {
+		return 0, invalidPatch("patch_invalid_index", map[string]interface{}{"index": token}, fmt.Sprintf("invalid array index %q", token))
+	}
+	if i > max {
+		return 0, patchConflict("patch_index_out_of_bounds", map[string]interface{}{"index": i}, fmt.Sprintf("array index %d is out of bounds", i))
+	}
+
+	return i, nil
+}
+
+// decodeJSONValue decodes raw into maps, slices and json.Number values.
+func decodeJSONValue(raw []byte) (interface{}, error) {
+	var v interface{}
+
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+
+	return v, nil
+}
+
+// jsonEqual reports whether two values decoded by decodeJSONValue are equal, comparing numbers by their value.
+func jsonEqual(a, b interface{}) bool {
+	switch x := a.(type) {
+	case json.Number:
+		y, ok := b.(json.Number)
+		if !ok {
+			return false
+		}
+		rx, okx := new(big.Rat).SetString(x.String())
+		ry, oky := new(big.Rat).SetString(y.String())
+		return okx && oky && rx.Cmp(ry) == 0
+
+	case map[string]interface{}:
+		y, ok := b.(map[string]interface{})
+		if !ok || len(x) != len(y) {
+			return false
+		}
+		for k, v := range x {
+			w, ok := y[k]
+			if !ok || !jsonEqual(v, w) {
+				return false
+			}
+		}
+		return true
+
+	case []interface{}:
+		y, ok := b.([]interface{})
+		if !ok || len(x) != len(y) {
+			return false
+		}
+		for i := range x {
+			if !jsonEqual(x[i], y[i]) {
+				return false
+			}
+		}
+		return true
+
+	default:
+		return a == b
+	}
+}
+
+// invalidPatch returns the JSONError of a malformed patch, with the message key and parameters of msg.
+func invalidPatch(key string, params map[string]interface{}, msg string) *JSONError {
+	return patchError(JSONErrInvalidPatch, key, params, msg)
+}
+
+// patchConflict returns the JSONError of a patch which does not apply to its document, with the message key and
+// parameters of msg.
+func patchConflict(key string, params map[string]interface{}, msg string) *JSONError {
+	return patchError(JSONErrPatchConflict, key, params, msg)
+}
+
+// missingPath returns the JSONError of an operation on path, which does not exist in the document.
+func missingPath(path []string) *JSONError {
+	p := formatPointer(path)
+	return patchConflict("patch_path_not_found", map[string]interface{}{"path": p}, fmt.Sprintf("path %s does not exist", p))
+}
+
+// patchError returns a JSONError of kind for an operation of a patch. Its parameters are never nil, so ApplyJSONPatch
+// can add the index of the operation.
+func patchError(kind JSONErrorKind, key string, params map[string]interface{}, msg string) *JSONError {
+	if params == nil {
+		params = make(map[string]interface{})
+	}
+
+	return &JSONError{Kind: kind, Key: key, Params: params, msg: msg}
+}
@@ -0,0 +1,227 @@
+package toolkit
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+var mergePatchTests = []struct {
+	name    string
+	doc     string
+	patch   string
+	want    string
+	changed []string
+}{
+	{name: "replace value", doc: `{"a": "b"}`, patch: `{"a": "c"}`, want: `{"a":"c"}`, changed: []string{"/a"}},
+	{name: "add value", doc: `{"a": "b"}`, patch: `{"b": "c"}`, want: `{"a":"b","b":"c"}`, changed: []string{"/b"}},
+	{name: "remove value", doc: `{"a": "b", "b": "c"}`, patch: `{"a": null}`, want: `{"b":"c"}`, changed: []string{"/a"}},
+	{name: "remove missing value", doc: `{"a": "b"}`, patch: `{"c": null}`, want: `{"a":"b"}`},
+	{name: "unchanged value", doc: `{"a": 1.0}`, patch: `{"a": 1}`, want: `{"a":1}`},
+	{name: "nested", doc: `{"a": {"b": "c", "d": "e"}}`, patch: `{"a": {"b": "x", "d": null}}`, want: `{"a":{"b":"x"}}`, changed: []string{"/a/b", "/a/d"}},
+	{name: "array replaced", doc: `{"a": [1, 2]}`, patch: `{"a": [3]}`, want: `{"a":[3]}`, changed: []string{"/a"}},
+	{name: "escaped key", doc: `{}`, patch: `{"a/b": 1}`, want: `{"a/b":1}`, changed: []string{"/a~1b"}},
+	{name: "not an object", doc: `{"a": "b"}`, patch: `["c"]`, want: `["c"]`, changed: []string{""}},
+}
+
+func TestTools_ApplyMergePatch(t *testing.T) {
+	for _, e := range mergePatchTests {
+		var testTools Tools
+
+		out, changed, err := testTools.ApplyMergePatch([]byte(e.doc), []byte(e.patch))
+		if err != nil {
+			t.Errorf("%s: unexpected error %v", e.name, err)
+			continue
+		}
+
+		if string(out) != e.want {
+			t.Errorf("%s: expected %s but got %s", e.name, e.want, out)
+		}
+
+		if len(changed) != len(e.changed) || (len(changed) > 0 && !reflect.DeepEqual(changed, e.changed)) {
+			t.Errorf("%s: expected changed paths %v but got %v", e.name, e.changed, changed)
+		}
+	}
+}
+
+var jsonPatchTests = []struct {
+	name    string
+	doc     string
+	patch   string
+	want    string
+	changed []string
+	kind    JSONErrorKind
+}{
+	{name: "add", doc: `{"a": 1}`, patch: `[{"op": "add", "path": "/b", "value": 2}]`, want: `{"a":1,"b":2}`, changed: []string{"/b"}},
+	{name: "add to array", doc: `{"a": [1, 3]}`, patch: `[{"op": "add", "path": "/a/1", "value": 2}]`, want: `{"a":[1,2,3]}`, changed: []string{"/a/1"}},
+	{name: "append to array", doc: `{"a": [1]}`, patch: `[{"op": "add", "path": "/a/-", "value": 2}]`, want: `{"a":[1,2]}`, changed: []string{"/a/1"}},
+	{name: "move to the end of an array", doc: `{"a": [1, 2, 3]}`, patch: `[{"op": "move", "from": "/a/0", "path": "/a/-"}]`, want: `{"a":[2,3,1]}`, changed: []string{"/a/0", "/a/2"}},
+	{name: "remove", doc: `{"a": 1, "b": 2}`, patch: `[{"op": "remove", "path": "/a"}]`, want: `{"b":2}`, changed: []string{"/a"}},
+	{name: "remove from array", doc: `[1, 2, 3]`, patch: `[{"op": "remove", "path": "/1"}]`, want: `[1,3]`, changed: []string{"/1"}},
+	{name: "replace", doc: `{"a": {"b": 1}}`, patch: `[{"op": "replace", "path": "/a/b", "value": "x"}]`, want: `{"a":{"b":"x"}}`, changed: []string{"/a/b"}},
+	{name: "move", doc: `{"a": {"b": 1}, "c": {}}`, patch: `[{"op": "move", "from": "/a/b", "path": "/c/d"}]`, want: `{"a":{},"c":{"d":1}}`, changed: []string{"/a/b", "/c/d"}},
+	{name: "copy", doc: `{"a": [1]}`, patch: `[{"op": "copy", "from": "/a", "path": "/b"}, {"op": "add", "path": "/b/-", "value": 2}]`, want: `{"a":[1],"b":[1,2]}`, changed: []string{"/b", "/b/1"}},
+	{name: "test", doc: `{"a": {"b": 1.0}}`, patch: `[{"op": "test", "path": "/a", "value": {"b": 1}}]`, want: `{"a":{"b":1.0}}`},
+	{name: "escaped path", doc: `{"a/b": {"~c": 1}}`, patch: `[{"op": "replace", "path": "/a~1b/~0c", "value": 2}]`, want: `{"a/b":{"~c":2}}`, changed: []string{"/a~1b/~0c"}},
+	{name: "add null", doc: `{}`, patch: `[{"op": "add", "path": "/a", "value": null}]`, want: `{"a":null}`, changed: []string{"/a"}},
+	{name: "replace with null", doc: `{"a": 1}`, patch: `[{"op": "replace", "path": "/a", "value": null}]`, want: `{"a":null}`, changed: []string{"/a"}},
+	{name: "test null", doc: `{"a": null}`, patch: `[{"op": "test", "path": "/a", "value": null}]`, want: `{"a":null}`},
+	{name: "failed test", doc: `{"a": 1}`, patch: `[{"op": "test", "path": "/a", "value": 2}]`, kind: JSONErrPatchConflict},
+	{name: "missing path", doc: `{"a": 1}`, patch: `[{"op": "remove", "path": "/b"}]`, kind: JSONErrPatchConflict},
+	{name: "index out of bounds", doc: `[1]`, patch: `[{"op": "add", "path": "/2", "value": 2}]`, kind: JSONErrPatchConflict},
+	{name: "replace missing", doc: `{}`, patch: `[{"op": "replace", "path": "/a", "value": 2}]`, kind: JSONErrPatchConflict},
+	{name: "move into itself", doc: `{"a": {}}`, patch: `[{"op": "move", "from": "/a", "path": "/a/b"}]`, kind: JSONErrInvalidPatch},
+	{name: "unknown operation", doc: `{}`, patch: `[{"op": "merge", "path": "/a"}]`, kind: JSONErrInvalidPatch},
+	{name: "missing value", doc: `{}`, patch: `[{"op": "add", "path": "/a"}]`, kind: JSONErrInvalidPatch},
+	{name: "bad pointer", doc: `{}`, patch: `[{"op": "add", "path": "a", "value": 1}]`, kind: JSONErrInvalidPatch},
+	{name: "leading zero index", doc: `[1, 2]`, patch: `[{"op": "remove", "path": "/01"}]`, kind: JSONErrInvalidPatch},
+	{name: "not an array", doc: `{}`, patch: `{"op": "add"}`, kind: JSONErrInvalidPatch},
+}
+
+func TestTools_ApplyJSONPatch(t *testing.T) {
+	for _, e := range jsonPatchTests {
+		var testTools Tools
+
+		out, changed, err := testTools.ApplyJSONPatch([]byte(e.doc), []byte(e.patch))
+
+		if e.kind != "" {
+			var jsonErr *JSONError
+			if !errors.As(err, &jsonErr) || jsonErr.Kind != e.kind {
+				t.Errorf("%s: expected a %s error but got %v", e.name, e.kind, err)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("%s: unexpected error %v", e.name, err)
+			continue
+		}
+
+		if string(out) != e.want {
+			t.Errorf("%s: expected %s but got %s", e.name, e.want, out)
+		}
+
+		if len(changed) != len(e.changed) || (len(changed) > 0 && !reflect.DeepEqual(changed, e.changed)) {
+			t.Errorf("%s: expected changed paths %v but got %v", e.name, e.changed, changed)
+		}
+	}
+}
+
+type patchedUser struct {
+	Name  string   `json:"name"`
+	Email string   `json:"email,omitempty"`
+	Tags  []string `json:"tags"`
+}
+
+var readPatchTests = []struct {
+	name        string
+	contentType string
+	patch       string
+	want        patchedUser
+	changed     []string
+	status      int
+}{
+	{name: "merge patch", contentType: "application/merge-patch+json", patch: `{"name": "bob", "email": null}`, want: patchedUser{Name: "bob", Tags: []string{"a"}}, changed: []string{"/email", "/name"}},
+	{name: "json patch", contentType: "application/json-patch+json", patch: `[{"op": "add", "path": "/tags/-", "value": "b"}]`, want: patchedUser{Name: "alice", Email: "alice@example.com", Tags: []string{"a", "b"}}, changed: []string{"/tags/1"}},
+	{name: "unknown field", contentType: "application/merge-patch+json", patch: `{"admin": true}`, status: http.StatusBadRequest},
+	{name: "wrong type", contentType: "application/merge-patch+json", patch: `{"name": 1}`, status: http.StatusBadRequest},
+	{name: "partly decoded", contentType: "application/merge-patch+json", patch: `{"name": "bob", "tags": 1}`, status: http.StatusBadRequest},
+	{name: "failed test", contentType: "application/json-patch+json", patch: `[{"op": "test", "path": "/name", "value": "bob"}]`, status: http.StatusConflict},
+	{name: "badly formed", contentType: "application/merge-patch+json", patch: `{"name": `, status: http.StatusBadRequest},
+}
+
+func TestTools_ReadPatch(t *testing.T) {
+	for _, e := range readPatchTests {
+		var testTools Tools
+
+		original := patchedUser{Name: "alice", Email: "alice@example.com", Tags: []string{"a"}}
+		user := original
+
+		req, _ := http.NewRequest("PATCH", "/", strings.NewReader(e.patch))
+		req.Header.Set("Content-Type", e.contentType)
+
+		changed, err := testTools.ReadPatch(httptest.NewRecorder(), req, &user)
+
+		if e.status != 0 {
+			var jsonErr *JSONError
+			if !errors.As(err, &jsonErr) || jsonErr.StatusCode() != e.status {
+				t.Errorf("%s: expected an error with status %d but got %v", e.name, e.status, err)
+			}
+			if !reflect.DeepEqual(user, original) {
+				t.Errorf("%s: rejected patch changed the target to %+v", e.name, user)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("%s: unexpected error %v", e.name, err)
+			continue
+		}
+
+		if !reflect.DeepEqual(user, e.want) {
+			got, _ := json.Marshal(user)
+			t.Errorf("%s: wrong patched value %s", e.name, got)
+		}
+
+		if !reflect.DeepEqual(changed, e.changed) {
+			t.Errorf("%s: expected changed paths %v but got %v", e.name, e.changed, changed)
+		}
+	}
+}
+
+func TestTools_ReadPatchDefaults(t *testing.T) {
+	var testTools Tools
+
+	settings := struct {
+		Name     string `json:"name"`
+		Priority int    `json:"priority,omitempty" default:"5"`
+	}{Name: "alice"}
+
+	req, _ := http.NewRequest("PATCH", "/", strings.NewReader(`{"name": "bob"}`))
+	req.Header.Set("Content-Type", "application/merge-patch+json")
+
+	if _, err := testTools.ReadPatch(httptest.NewRecorder(), req, &settings); err != nil {
+		t.Fatal(err)
+	}
+
+	if settings.Name != "bob" || settings.Priority != 0 {
+		t.Errorf("wrong patched value %+v", settings)
+	}
+}
+
+type patchedAccount struct {
+	ID      int    `json:"-"`
+	Name    string `json:"name"`
+	hash    string
+	Profile struct {
+		Bio    string `json:"bio"`
+		Secret string `json:"-"`
+	} `json:"profile"`
+}
+
+func TestTools_ReadPatchHiddenFields(t *testing.T) {
+	var testTools Tools
+
+	account := patchedAccount{ID: 42, Name: "a", hash: "x1"}
+	account.Profile.Bio = "hi"
+	account.Profile.Secret = "s"
+
+	req, _ := http.NewRequest("PATCH", "/", strings.NewReader(`{"name": "b", "profile": {"bio": null}}`))
+	req.Header.Set("Content-Type", "application/merge-patch+json")
+
+	if _, err := testTools.ReadPatch(httptest.NewRecorder(), req, &account); err != nil {
+		t.Fatal(err)
+	}
+
+	if account.ID != 42 || account.hash != "x1" || account.Profile.Secret != "s" {
+		t.Errorf("fields JSON does not carry were lost: %+v", account)
+	}
+
+	if account.Name != "b" || account.Profile.Bio != "" {
+		t.Errorf("wrong patched value %+v", account)
+	}
+}
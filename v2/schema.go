@@ -0,0 +1,381 @@
+package toolkit
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// schemaMu serializes the compilation of schemas, which Validate does on first use for schemas built as struct literals.
+var schemaMu sync.Mutex
+
+// schemaTypeNames are the types the type keyword may name.
+var schemaTypeNames = map[string]bool{
+	"null": true, "boolean": true, "object": true, "array": true, "number": true, "integer": true, "string": true,
+}
+
+// Schema is a JSON Schema, limited to the draft 2020-12 keywords type, properties, required, enum, pattern, minLength,
+// maxLength, minimum, maximum, exclusiveMinimum, exclusiveMaximum, items, minItems, maxItems, oneOf and anyOf. Other
+// keywords are ignored. A Schema built as a struct literal must be compiled with Compile, or it is compiled by its first
+// Validate.
+type Schema struct {
+	Type             schemaTypes        `json:"type"`
+	Properties       map[string]*Schema `json:"properties"`
+	Required         []string           `json:"required"`
+	Enum             []json.RawMessage  `json:"enum"`
+	Pattern          string             `json:"pattern"`
+	MinLength        *int               `json:"minLength"`
+	MaxLength        *int               `json:"maxLength"`
+	Minimum          *json.Number       `json:"minimum"`
+	Maximum          *json.Number       `json:"maximum"`
+	ExclusiveMinimum *json.Number       `json:"exclusiveMinimum"`
+	ExclusiveMaximum *json.Number       `json:"exclusiveMaximum"`
+	Items            *Schema            `json:"items"`
+	MinItems         *int               `json:"minItems"`
+	MaxItems         *int               `json:"maxItems"`
+	OneOf            []*Schema          `json:"oneOf"`
+	AnyOf            []*Schema          `json:"anyOf"`
+
+	pattern  *regexp.Regexp
+	enum     []interface{}
+	compiled bool
+}
+
+// schemaTypes is the type keyword of a schema, which may be a single type or a list of them.
+type schemaTypes []string
+
+// UnmarshalJSON reads a type keyword given either as a string or as an array of strings.
+func (s *schemaTypes) UnmarshalJSON(b []byte) error {
+	var one string
+	if err := json.Unmarshal(b, &one); err == nil {
+		*s = schemaTypes{one}
+		return nil
+	}
+
+	var many []string
+	if err := json.Unmarshal(b, &many); err != nil {
+		return errors.New("schema type must be a string or an array of strings")
+	}
+	*s = many
+
+	return nil
+}
+
+// ParseSchema parses a JSON Schema document and compiles it.
+func ParseSchema(data []byte) (*Schema, error) {
+	var s Schema
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, err
+	}
+
+	if err := s.Compile(); err != nil {
+		return nil, err
+	}
+
+	return &s, nil
+}
+
+// Compile checks s and all its subschemas, compiling their patterns and enums, and returns the first keyword which is not
+// valid. It must be called again after s is changed.
+func (s *Schema) Compile() error {
+	schemaMu.Lock()
+	defer schemaMu.Unlock()
+
+	return s.compile()
+}
+
+// compile prepares the patterns and enums of s and of all its subschemas.
+func (s *Schema) compile() error {
+	for _, typ := range s.Type {
+		if !schemaTypeNames[typ] {
+			return fmt.Errorf("schema type %q is not valid", typ)
+		}
+	}
+
+	s.pattern = nil
+	if s.Pattern != "" {
+		re, err := regexp.Compile(s.Pattern)
+		if err != nil {
+			return fmt.Errorf("schema pattern %q: %w", s.Pattern, err)
+		}
+		s.pattern = re
+	}
+
+	s.enum = nil
+	for _, raw := range s.Enum {
+		v, err := decodeJSONValue(raw)
+		if err != nil {
+			return fmt.Errorf("schema enum value %s: %w", raw, err)
+		}
+		s.enum = append(s.enum, v)
+	}
+
+	for _, n := range []*json.Number{s.Minimum, s.Maximum, s.ExclusiveMinimum, s.ExclusiveMaximum} {
+		if n == nil {
+			continue
+		}
+		if _, ok := new(big.Rat).SetString(n.String()); !ok {
+			return fmt.Errorf("schema bound %q is not a number", n.String())
+		}
+	}
+
+	for _, n := range []*int{s.MinLength, s.MaxLength, s.MinItems, s.MaxItems} {
+		if n != nil && *n < 0 {
+			return fmt.Errorf("schema length %d must not be negative", *n)
+		}
+	}
+
+	var subschemas []*Schema
+	for _, p := range s.Properties {
+		subschemas = append(subschemas, p)
+	}
+	subschemas = append(subschemas, s.Items)
+	subschemas = append(subschemas, s.OneOf...)
+	subschemas = append(subschemas, s.AnyOf...)
+
+	for _, sub := range subschemas {
+		if sub == nil {
+			continue
+		}
+		if err := sub.compile(); err != nil {
+			return err
+		}
+	}
+
+	s.compiled = true
+
+	return nil
+}
+
+// RegisterSchema registers the JSON Schema schema for the type of target, which may be given as a value or a pointer.
+// ReadJSON then validates every body decoded into that type against the schema before decoding it, returning all the
+// violations at once as ValidationErrors, with the JSON Pointer of the offending value as Field.
+func (t *Tools) RegisterSchema(target interface{}, schema []byte) error {
+	s, err := ParseSchema(schema)
+	if err != nil {
+		return err
+	}
+
+	if t.schemas == nil {
+		t.schemas = make(map[reflect.Type]*Schema)
+	}
+	t.schemas[schemaKey(target)] = s
+
+	return nil
+}
+
+// schemaFor returns the schema registered for the type of target, if any.
+func (t *Tools) schemaFor(target interface{}) *Schema {
+	if t.schemas == nil {
+		return nil
+	}
+
+	return t.schemas[schemaKey(target)]
+}
+
+// schemaKey returns the type schemas are registered under for target, without its pointers.
+func schemaKey(target interface{}) reflect.Type {
+	typ := reflect.TypeOf(target)
+	for typ != nil && typ.Kind() == reflect.Pointer {
+		typ = typ.Elem()
+	}
+
+	return typ
+}
+
+// Validate checks the JSON document raw against s, returning every violation as ValidationErrors. A schema which was not
+// compiled yet is compiled first, and its error returned if it is not valid.
+func (s *Schema) Validate(raw []byte) error {
+	schemaMu.Lock()
+	var err error
+	if !s.compiled {
+		err = s.compile()
+	}
+	schemaMu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	v, err := decodeJSONValue(raw)
+	if err != nil {
+		return err
+	}
+
+	var errs ValidationErrors
+	s.validate(v, "", &errs)
+
+	if len(errs) > 0 {
+		return errs
+	}
+
+	return nil
+}
+
+// validate checks v, found at the JSON Pointer p, against s.
+func (s *Schema) validate(v interface{}, p string, errs *ValidationErrors) {
+	fail := func(keyword, param, msg string) {
+		*errs = append(*errs, FieldError{Field: p, Rule: keyword, Param: param, Message: msg})
+	}
+
+	if len(s.Type) > 0 && !s.hasType(v) {
+		fail("type", strings.Join(s.Type, " "), fmt.Sprintf("must be of type %s", strings.Join(s.Type, " or ")))
+		return
+	}
+
+	if s.enum != nil {
+		found := false
+		for _, e := range s.enum {
+			if jsonEqual(v, e) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			values := make([]string, len(s.Enum))
+			for i, e := range s.Enum {
+				values[i] = string(e)
+			}
+			fail("enum", strings.Join(values, " "), fmt.Sprintf("must be one of %s", strings.Join(values, ", ")))
+		}
+	}
+
+	switch x := v.(type) {
+	case string:
+		n := utf8.RuneCountInString(x)
+		if s.MinLength != nil && n < *s.MinLength {
+			fail("minLength", fmt.Sprint(*s.MinLength), fmt.Sprintf("must be at least %d characters long", *s.MinLength))
+		}
+		if s.MaxLength != nil && n > *s.MaxLength {
+			fail("maxLength", fmt.Sprint(*s.MaxLength), fmt.Sprintf("must be at most %d characters long", *s.MaxLength))
+		}
+		if s.pattern != nil && !s.pattern.MatchString(x) {
+			fail("pattern", s.Pattern, fmt.Sprintf("must match the pattern %s", s.Pattern))
+		}
+
+	case json.Number:
+		if s.Minimum != nil && compareNumbers(x, *s.Minimum) < 0 {
+			fail("minimum", s.Minimum.String(), fmt.Sprintf("must be at least %s", s.Minimum))
+		}
+		if s.Maximum != nil && compareNumbers(x, *s.Maximum) > 0 {
+			fail("maximum", s.Maximum.String(), fmt.Sprintf("must be at most %s", s.Maximum))
+		}
+		if s.ExclusiveMinimum != nil && compareNumbers(x, *s.ExclusiveMinimum) <= 0 {
+			fail("exclusiveMinimum", s.ExclusiveMinimum.String(), fmt.Sprintf("must be greater than %s", s.ExclusiveMinimum))
+		}
+		if s.ExclusiveMaximum != nil && compareNumbers(x, *s.ExclusiveMaximum) >= 0 {
+			fail("exclusiveMaximum", s.ExclusiveMaximum.String(), fmt.Sprintf("must be less than %s", s.ExclusiveMaximum))
+		}
+
+	case []interface{}:
+		if s.MinItems != nil && len(x) < *s.MinItems {
+			fail("minItems", fmt.Sprint(*s.MinItems), fmt.Sprintf("must have at least %d items", *s.MinItems))
+		}
+		if s.MaxItems != nil && len(x) > *s.MaxItems {
+			fail("maxItems", fmt.Sprint(*s.MaxItems), fmt.Sprintf("must have at most %d items", *s.MaxItems))
+		}
+		if s.Items != nil {
+			for i, item := range x {
+				s.Items.validate(item, fmt.Sprintf("%s/%d", p, i), errs)
+			}
+		}
+
+	case map[string]interface{}:
+		for _, name := range s.Required {
+			if _, ok := x[name]; !ok {
+				*errs = append(*errs, FieldError{Field: p + "/" + escapePointerToken(name), Rule: "required", Message: "is required"})
+			}
+		}
+
+		names := make([]string, 0, len(s.Properties))
+		for name := range s.Properties {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		for _, name := range names {
+			if value, ok := x[name]; ok {
+				s.Properties[name].validate(value, p+"/"+escapePointerToken(name), errs)
+			}
+		}
+	}
+
+	if len(s.AnyOf) > 0 && s.matching(s.AnyOf, v) == 0 {
+		fail("anyOf", "", "must match at least one of the allowed schemas")
+	}
+
+	if len(s.OneOf) > 0 && s.matching(s.OneOf, v) != 1 {
+		fail("oneOf", "", "must match exactly one of the allowed schemas")
+	}
+}
+
+// matching returns how many of schemas accept v.
+func (s *Schema) matching(schemas []*Schema, v interface{}) int {
+	n := 0
+	for _, sub := range schemas {
+		var errs ValidationErrors
+		sub.validate(v, "", &errs)
+		if len(errs) == 0 {
+			n++
+		}
+	}
+
+	return n
+}
+
+// hasType reports whether v is of one of the types allowed by s.
+func (s *Schema) hasType(v interface{}) bool {
+	for _, typ := range s.Type {
+		switch x := v.(type) {
+		case nil:
+			if typ == "null" {
+				return true
+			}
+		case bool:
+			if typ == "boolean" {
+				return true
+			}
+		case string:
+			if typ == "string" {
+				return true
+			}
+		case json.Number:
+			if typ == "number" {
+				return true
+			}
+			if typ == "integer" {
+				if r, ok := new(big.Rat).SetString(x.String()); ok && r.IsInt() {
+					return true
+				}
+			}
+		case []interface{}:
+			if typ == "array" {
+				return true
+			}
+		case map[string]interface{}:
+			if typ == "object" {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// compareNumbers compares two JSON numbers by their value, as big.Rat.Cmp does. Numbers which can not be parsed are
+// considered equal.
+func compareNumbers(a, b json.Number) int {
+	ra, oka := new(big.Rat).SetString(a.String())
+	rb, okb := new(big.Rat).SetString(b.String())
+	if !oka || !okb {
+		return 0
+	}
+
+	return ra.Cmp(rb)
+}
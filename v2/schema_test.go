@@ -0,0 +1,153 @@
+package toolkit
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+var testSchema = `{
+	"$schema": "https://json-schema.org/draft/2020-12/schema",
+	"type": "object",
+	"required": ["name", "qty"],
+	"properties": {
+		"name": {"type": "string", "minLength": 2, "maxLength": 10, "pattern": "^[a-z]+$"},
+		"qty": {"type": "integer", "minimum": 1, "maximum": 10},
+		"price": {"type": "number", "exclusiveMinimum": 0},
+		"status": {"enum": ["draft", "published"]},
+		"tags": {"type": "array", "maxItems": 2, "items": {"type": "string"}},
+		"contact": {"oneOf": [
+			{"type": "object", "required": ["email"]},
+			{"type": "object", "required": ["phone"]}
+		]},
+		"ref": {"anyOf": [{"type": "string"}, {"type": "integer"}]},
+		"note": {"type": ["string", "null"]}
+	}
+}`
+
+type schemaItem struct {
+	Name    string            `json:"name"`
+	Qty     int               `json:"qty"`
+	Price   float64           `json:"price"`
+	Status  string            `json:"status"`
+	Tags    []string          `json:"tags"`
+	Contact map[string]string `json:"contact"`
+	Ref     interface{}       `json:"ref"`
+	Note    *string           `json:"note"`
+}
+
+var schemaTests = []struct {
+	name   string
+	json   string
+	errors map[string]string
+}{
+	{name: "valid", json: `{"name": "pen", "qty": 2, "price": 1.5, "status": "draft", "tags": ["a"], "contact": {"email": "a@b.c"}, "ref": 3, "note": null}`},
+	{name: "missing required", json: `{}`, errors: map[string]string{"/name": "required", "/qty": "required"}},
+	{name: "wrong type", json: `{"name": 1, "qty": 1.5}`, errors: map[string]string{"/name": "type", "/qty": "type"}},
+	{name: "string rules", json: `{"name": "a", "qty": 1}`, errors: map[string]string{"/name": "minLength"}},
+	{name: "pattern", json: `{"name": "Pen", "qty": 1}`, errors: map[string]string{"/name": "pattern"}},
+	{name: "number bounds", json: `{"name": "pen", "qty": 11, "price": 0}`, errors: map[string]string{"/qty": "maximum", "/price": "exclusiveMinimum"}},
+	{name: "enum", json: `{"name": "pen", "qty": 1, "status": "deleted"}`, errors: map[string]string{"/status": "enum"}},
+	{name: "items", json: `{"name": "pen", "qty": 1, "tags": ["a", 2, "c"]}`, errors: map[string]string{"/tags": "maxItems", "/tags/1": "type"}},
+	{name: "one of none", json: `{"name": "pen", "qty": 1, "contact": {}}`, errors: map[string]string{"/contact": "oneOf"}},
+	{name: "one of both", json: `{"name": "pen", "qty": 1, "contact": {"email": "a", "phone": "b"}}`, errors: map[string]string{"/contact": "oneOf"}},
+	{name: "any of", json: `{"name": "pen", "qty": 1, "ref": true}`, errors: map[string]string{"/ref": "anyOf"}},
+	{name: "not an object", json: `[]`, errors: map[string]string{"": "type"}},
+}
+
+func TestTools_ReadJSONSchema(t *testing.T) {
+	for _, e := range schemaTests {
+		var testTools Tools
+		if err := testTools.RegisterSchema(&schemaItem{}, []byte(testSchema)); err != nil {
+			t.Fatal(err)
+		}
+
+		var decoded schemaItem
+		req, _ := http.NewRequest("POST", "/", strings.NewReader(e.json))
+		err := testTools.ReadJSON(httptest.NewRecorder(), req, &decoded)
+
+		if len(e.errors) == 0 {
+			if err != nil {
+				t.Errorf("%s: unexpected error %v", e.name, err)
+			}
+			continue
+		}
+
+		var errs ValidationErrors
+		if !errors.As(err, &errs) {
+			t.Errorf("%s: expected validation errors but got %v", e.name, err)
+			continue
+		}
+
+		if len(errs) != len(e.errors) {
+			t.Errorf("%s: expected %d errors but got %v", e.name, len(e.errors), errs)
+		}
+
+		for _, fe := range errs {
+			if e.errors[fe.Field] != fe.Rule {
+				t.Errorf("%s: unexpected %s error on %q", e.name, fe.Rule, fe.Field)
+			}
+		}
+	}
+}
+
+func TestTools_ReadJSONSchemaBadlyFormed(t *testing.T) {
+	var testTools Tools
+	if err := testTools.RegisterSchema(schemaItem{}, []byte(testSchema)); err != nil {
+		t.Fatal(err)
+	}
+
+	var decoded schemaItem
+	req, _ := http.NewRequest("POST", "/", strings.NewReader(`{"name": `))
+	err := testTools.ReadJSON(httptest.NewRecorder(), req, &decoded)
+
+	var jsonErr *JSONError
+	if !errors.As(err, &jsonErr) || jsonErr.Kind != JSONErrSyntax {
+		t.Errorf("expected a syntax error but got %v", err)
+	}
+}
+
+func TestParseSchema(t *testing.T) {
+	if _, err := ParseSchema([]byte(`{"properties": {"a": {"pattern": "("}}}`)); err == nil {
+		t.Error("expected an error for an invalid pattern")
+	}
+
+	if _, err := ParseSchema([]byte(`{"type": 1}`)); err == nil {
+		t.Error("expected an error for an invalid type")
+	}
+
+	if _, err := ParseSchema([]byte(`{"type": "text"}`)); err == nil {
+		t.Error("expected an error for an unknown type")
+	}
+
+	if _, err := ParseSchema([]byte(`{"minimum": "ten"}`)); err == nil {
+		t.Error("expected an error for a bound which is not a number")
+	}
+}
+
+func TestSchema_Compile(t *testing.T) {
+	s := &Schema{Properties: map[string]*Schema{"code": {Type: schemaTypes{"string"}, Pattern: "^[A-Z]{3}$"}}}
+	if err := s.Compile(); err != nil {
+		t.Fatal(err)
+	}
+
+	var errs ValidationErrors
+	if err := s.Validate([]byte(`{"code": "abc"}`)); !errors.As(err, &errs) || errs[0].Rule != "pattern" {
+		t.Errorf("expected a pattern error but got %v", err)
+	}
+
+	lazy := &Schema{Pattern: "^[0-9]+$"}
+	if err := lazy.Validate([]byte(`"12a"`)); !errors.As(err, &errs) {
+		t.Errorf("expected a schema built as a literal to be compiled by Validate, got %v", err)
+	}
+
+	bad := &Schema{Items: &Schema{Pattern: "("}}
+	if err := bad.Compile(); err == nil {
+		t.Error("expected an error for an invalid pattern")
+	}
+	if err := bad.Validate([]byte(`["a"]`)); err == nil || errors.As(err, &errs) {
+		t.Errorf("expected the schema error from Validate but got %v", err)
+	}
+}
@@ -0,0 +1,163 @@
+package toolkit
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+var (
+	// ErrNoSigningKey is returned when a URL has to be signed or verified but Tools.SigningKeys is empty, or when a webhook
+	// signature has to be verified without a secret.
+	ErrNoSigningKey = errors.New("no signing key configured")
+	// ErrInvalidSignature is returned when a signed URL or webhook is malformed or its signature does not match. For
+	// webhooks it is wrapped in a SignatureError.
+	ErrInvalidSignature = errors.New("invalid signature")
+	// ErrSignatureExpired is returned when a signed URL is used after its expiry, or a webhook was signed outside of its
+	// replay window, wrapped in a SignatureError.
+	ErrSignatureExpired = errors.New("signature has expired")
+)
+
+// SigningKey is a secret used to sign download URLs. The ID is embedded in every URL it signs, so keys can be rotated by
+// adding a new key in front of Tools.SigningKeys and removing the old one once its URLs have expired.
+type SigningKey struct {
+	ID     string
+	Secret []byte
+}
+
+// SignedURLOptions holds the optional settings of a signed URL.
+type SignedURLOptions struct {
+	// ClientIP binds the URL to a single client address, as seen in http.Request.RemoteAddr.
+	ClientIP string
+	// DisplayName is the name the file is downloaded as.
+	DisplayName string
+}
+
+// SignedFile is the information carried by a verified signed URL.
+type SignedFile struct {
+	File        string
+	DisplayName string
+	Expires     time.Time
+}
+
+// SignURL adds the file, its expiry and a HMAC-SHA256 signature to the query of rawURL, using the first of
+// t.SigningKeys. The result is meant to be served by SignedDownloadHandler.
+func (t *Tools) SignURL(rawURL, file string, ttl time.Duration, opts ...SignedURLOptions) (string, error) {
+	if len(t.SigningKeys) == 0 {
+		return "", ErrNoSigningKey
+	}
+
+	var opt SignedURLOptions
+	if len(opts) > 0 {
+		opt = opts[0]
+	}
+
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", err
+	}
+
+	key := t.SigningKeys[0]
+	q := u.Query()
+	q.Set("file", file)
+	q.Set("exp", strconv.FormatInt(time.Now().Add(ttl).Unix(), 10))
+	q.Del("ip")
+	if opt.ClientIP != "" {
+		q.Set("ip", opt.ClientIP)
+	}
+	q.Del("name")
+	if opt.DisplayName != "" {
+		q.Set("name", opt.DisplayName)
+	}
+	q.Set("kid", key.ID)
+	q.Set("sig", signature(key.Secret, q))
+
+	u.RawQuery = q.Encode()
+
+	return u.String(), nil
+}
+
+// VerifySignedURL checks the signature, expiry and client binding of a request made to a URL built by SignURL. Signatures
+// are compared in constant time, and every key of t.SigningKeys is accepted.
+func (t *Tools) VerifySignedURL(r *http.Request) (*SignedFile, error) {
+	if len(t.SigningKeys) == 0 {
+		return nil, ErrNoSigningKey
+	}
+
+	q := r.URL.Query()
+
+	sig, err := base64.RawURLEncoding.DecodeString(q.Get("sig"))
+	if err != nil || q.Get("file") == "" {
+		return nil, ErrInvalidSignature
+	}
+
+	valid := false
+	for _, key := range t.SigningKeys {
+		if key.ID != q.Get("kid") {
+			continue
+		}
+		expected, _ := base64.RawURLEncoding.DecodeString(signature(key.Secret, q))
+		if hmac.Equal(sig, expected) {
+			valid = true
+		}
+	}
+	if !valid {
+		return nil, ErrInvalidSignature
+	}
+
+	exp, err := strconv.ParseInt(q.Get("exp"), 10, 64)
+	if err != nil {
+		return nil, ErrInvalidSignature
+	}
+	if time.Now().Unix() > exp {
+		return nil, ErrSignatureExpired
+	}
+
+	if ip := q.Get("ip"); ip != "" {
+		host, _, err := net.SplitHostPort(r.RemoteAddr)
+		if err != nil {
+			host = r.RemoteAddr
+		}
+		if host != ip {
+			return nil, ErrInvalidSignature
+		}
+	}
+
+	return &SignedFile{
+		File:        q.Get("file"),
+		DisplayName: q.Get("name"),
+		Expires:     time.Unix(exp, 0),
+	}, nil
+}
+
+// SignedDownloadHandler returns a http.Handler which verifies requests with VerifySignedURL and serves the signed file from
+// inside baseDir with DownloadFile. Requests with a bad or expired signature get a JSON 403.
+func (t *Tools) SignedDownloadHandler(baseDir string, opts ...DownloadOptions) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		signed, err := t.VerifySignedURL(r)
+		if err != nil {
+			_ = t.ErrorJSON(w, err, http.StatusForbidden)
+			return
+		}
+
+		t.DownloadFile(w, r, baseDir, signed.File, signed.DisplayName, opts...)
+	})
+}
+
+// signature returns the base64 encoded HMAC-SHA256 of the signed query parameters. Every parameter is prefixed with its
+// length, so values can not be shifted from one parameter to the next.
+func signature(secret []byte, q url.Values) string {
+	mac := hmac.New(sha256.New, secret)
+	for _, key := range []string{"file", "exp", "ip", "name", "kid"} {
+		fmt.Fprintf(mac, "%d:%s;", len(q.Get(key)), q.Get(key))
+	}
+
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
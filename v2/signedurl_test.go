@@ -0,0 +1,112 @@
+package toolkit
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+var signedURLTests = []struct {
+	name       string
+	ttl        time.Duration
+	opts       SignedURLOptions
+	tamper     func(q url.Values)
+	remoteAddr string
+	keys       []SigningKey
+	statusCode int
+}{
+	{name: "valid", ttl: time.Minute, statusCode: http.StatusOK},
+	{name: "expired", ttl: -time.Minute, statusCode: http.StatusForbidden},
+	{name: "tampered file", ttl: time.Minute, tamper: func(q url.Values) { q.Set("file", "img.jpg") }, statusCode: http.StatusForbidden},
+	{name: "tampered expiry", ttl: time.Minute, tamper: func(q url.Values) { q.Set("exp", "99999999999") }, statusCode: http.StatusForbidden},
+	{name: "missing signature", ttl: time.Minute, tamper: func(q url.Values) { q.Del("sig") }, statusCode: http.StatusForbidden},
+	{name: "bound ip", ttl: time.Minute, opts: SignedURLOptions{ClientIP: "10.0.0.1"}, remoteAddr: "10.0.0.1:1234", statusCode: http.StatusOK},
+	{name: "wrong ip", ttl: time.Minute, opts: SignedURLOptions{ClientIP: "10.0.0.1"}, remoteAddr: "10.0.0.2:1234", statusCode: http.StatusForbidden},
+	{name: "dropped ip", ttl: time.Minute, opts: SignedURLOptions{ClientIP: "10.0.0.1"}, tamper: func(q url.Values) { q.Del("ip") }, statusCode: http.StatusForbidden},
+	{name: "rotated key", ttl: time.Minute, keys: []SigningKey{{ID: "new", Secret: []byte("new secret")}, {ID: "old", Secret: []byte("old secret")}}, statusCode: http.StatusOK},
+	{name: "retired key", ttl: time.Minute, keys: []SigningKey{{ID: "new", Secret: []byte("new secret")}}, statusCode: http.StatusForbidden},
+}
+
+func TestTools_SignedDownloadHandler(t *testing.T) {
+	for _, e := range signedURLTests {
+		var testTools Tools
+		testTools.SigningKeys = []SigningKey{{ID: "old", Secret: []byte("old secret")}}
+
+		signed, err := testTools.SignURL("https://example.com/download", "pic.jpg", e.ttl, e.opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		u, _ := url.Parse(signed)
+		if e.tamper != nil {
+			q := u.Query()
+			e.tamper(q)
+			u.RawQuery = q.Encode()
+		}
+
+		if e.keys != nil {
+			testTools.SigningKeys = e.keys
+		}
+
+		rr := httptest.NewRecorder()
+		req := httptest.NewRequest("GET", u.String(), nil)
+		if e.remoteAddr != "" {
+			req.RemoteAddr = e.remoteAddr
+		}
+
+		testTools.SignedDownloadHandler("./testdata").ServeHTTP(rr, req)
+
+		if rr.Code != e.statusCode {
+			t.Errorf("%s: expected status %d but got %d", e.name, e.statusCode, rr.Code)
+		}
+	}
+}
+
+func TestTools_SignedDownloadHandlerMetadata(t *testing.T) {
+	base := t.TempDir()
+	if err := os.WriteFile(filepath.Join(base, "pic.jpg.meta.json"), []byte(`{}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var testTools Tools
+	testTools.SigningKeys = []SigningKey{{ID: "k", Secret: []byte("secret")}}
+
+	signed, err := testTools.SignURL("https://example.com/download", "pic.jpg.meta.json", time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rr := httptest.NewRecorder()
+	testTools.SignedDownloadHandler(base).ServeHTTP(rr, httptest.NewRequest("GET", signed, nil))
+
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("expected status %d for a signed sidecar but got %d", http.StatusNotFound, rr.Code)
+	}
+}
+
+func TestTools_SignURLDisplayName(t *testing.T) {
+	var testTools Tools
+
+	if _, err := testTools.SignURL("/download", "pic.jpg", time.Minute); err != ErrNoSigningKey {
+		t.Errorf("expected ErrNoSigningKey but got %v", err)
+	}
+
+	testTools.SigningKeys = []SigningKey{{ID: "k", Secret: []byte("secret")}}
+	signed, err := testTools.SignURL("/download", "pic.jpg", time.Minute, SignedURLOptions{DisplayName: "rowdy-cat.jpg"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	file, err := testTools.VerifySignedURL(httptest.NewRequest("GET", signed, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if file.File != "pic.jpg" || file.DisplayName != "rowdy-cat.jpg" {
+		t.Errorf("wrong signed file: %+v", file)
+	}
+}
@@ -0,0 +1,156 @@
+package toolkit
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+const (
+	// JSONErrDuplicateKey is an object holding the same key twice, when Tools.RejectDuplicateKeys is set.
+	JSONErrDuplicateKey JSONErrorKind = "duplicate_key"
+	// JSONErrTooDeep is a body nested deeper than Tools.MaxJSONDepth.
+	JSONErrTooDeep JSONErrorKind = "too_deep"
+	// JSONErrArrayTooLong is an array holding more than Tools.MaxJSONArrayLength elements.
+	JSONErrArrayTooLong JSONErrorKind = "array_too_long"
+)
+
+// jsonFrame is an object or array being scanned by checkJSONStructure.
+type jsonFrame struct {
+	path  string
+	array bool
+	// typ is the Go type the object or array decodes into, or nil when unknown.
+	typ      reflect.Type
+	length   int
+	keys     map[string]bool
+	isKey    bool
+	lastKey  string
+	lastType reflect.Type
+}
+
+// checkJSONStructure scans raw for duplicate keys, excessive nesting and overlong arrays, according to
+// t.RejectDuplicateKeys, t.MaxJSONDepth and t.MaxJSONArrayLength. typ is the type raw decodes into, or nil when unknown.
+// In objects decoding into a struct, keys which only differ by case count as duplicates, as encoding/json matches them
+// to the same field; maps and interfaces keep every key, so they are matched exactly. Syntax errors are left for the
+// decoder to report.
+func (t *Tools) checkJSONStructure(raw []byte, typ reflect.Type) error {
+	if !t.RejectDuplicateKeys && t.MaxJSONDepth <= 0 && t.MaxJSONArrayLength <= 0 {
+		return nil
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+
+	var stack []*jsonFrame
+
+	fail := func(kind JSONErrorKind, path string, max int, msg string) error {
+		e := &JSONError{Kind: kind, Key: string(kind), Field: path, Offset: dec.InputOffset(), msg: msg}
+		if max > 0 {
+			e.Params = map[string]interface{}{"max": max}
+		}
+		if e.Offset > 0 && e.Offset <= int64(len(raw)) {
+			e.Line, e.Column = lineColumn(raw, e.Offset)
+		}
+		return e
+	}
+
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			return nil
+		}
+
+		var parent *jsonFrame
+		if len(stack) > 0 {
+			parent = stack[len(stack)-1]
+		}
+
+		//	a string in key position of an object is a key, not a value
+		if parent != nil && !parent.array && parent.isKey {
+			if key, ok := tok.(string); ok {
+				seen := key
+				if parent.typ != nil && parent.typ.Kind() == reflect.Struct {
+					seen = strings.ToLower(key)
+				}
+				if t.RejectDuplicateKeys && parent.keys[seen] {
+					path := joinPath(parent.path, key)
+					return fail(JSONErrDuplicateKey, path, 0, fmt.Sprintf("body contains duplicate key %q", path))
+				}
+				parent.keys[seen] = true
+				parent.lastKey = key
+				parent.lastType = memberType(parent.typ, key)
+				parent.isKey = false
+				continue
+			}
+		}
+
+		//	tok is a value, or the end of the current object or array
+		if delim, ok := tok.(json.Delim); ok && (delim == '}' || delim == ']') {
+			stack = stack[:len(stack)-1]
+			continue
+		}
+
+		path, valueType := "", typ
+		if parent != nil {
+			if parent.array {
+				path, valueType = parent.path+"["+strconv.Itoa(parent.length)+"]", memberType(parent.typ, "")
+				parent.length++
+				if t.MaxJSONArrayLength > 0 && parent.length > t.MaxJSONArrayLength {
+					return fail(JSONErrArrayTooLong, parent.path, t.MaxJSONArrayLength, fmt.Sprintf("body contains an array with more than %d elements", t.MaxJSONArrayLength))
+				}
+			} else {
+				path, valueType = joinPath(parent.path, parent.lastKey), parent.lastType
+				parent.isKey = true
+			}
+		}
+
+		if delim, ok := tok.(json.Delim); ok {
+			if t.MaxJSONDepth > 0 && len(stack)+1 > t.MaxJSONDepth {
+				return fail(JSONErrTooDeep, path, t.MaxJSONDepth, fmt.Sprintf("body must not be nested more than %d levels deep", t.MaxJSONDepth))
+			}
+
+			frame := &jsonFrame{path: path, array: delim == '[', typ: structureType(valueType)}
+			if !frame.array {
+				frame.keys = make(map[string]bool)
+				frame.isKey = true
+			}
+			stack = append(stack, frame)
+		}
+	}
+}
+
+// structureType returns the type whose structure a JSON object or array decoding into typ follows, or nil when it is
+// unknown or decoded by the type itself.
+func structureType(typ reflect.Type) reflect.Type {
+	if typ == nil || typ.Implements(jsonUnmarshalerType) || reflect.PointerTo(typ).Implements(jsonUnmarshalerType) {
+		return nil
+	}
+
+	if typ.Kind() == reflect.Pointer {
+		return structureType(typ.Elem())
+	}
+
+	return typ
+}
+
+// memberType returns the type of the member key of an object, or of the elements of an array, decoding into typ, or nil
+// when it is unknown.
+func memberType(typ reflect.Type, key string) reflect.Type {
+	if typ == nil {
+		return nil
+	}
+
+	switch typ.Kind() {
+	case reflect.Struct:
+		if sf, _, ok := structFieldByJSONName(typ, key); ok {
+			return sf.Type
+		}
+	case reflect.Map, reflect.Slice, reflect.Array:
+		return typ.Elem()
+	}
+
+	return nil
+}
@@ -0,0 +1,116 @@
+package toolkit
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+var strictJSONTests = []struct {
+	name      string
+	json      string
+	duplicate bool
+	depth     int
+	arrayLen  int
+	kind      JSONErrorKind
+	field     string
+}{
+	{name: "duplicate allowed", json: `{"role": "user", "role": "admin"}`},
+	{name: "duplicate key", json: `{"role": "user", "role": "admin"}`, duplicate: true, kind: JSONErrDuplicateKey, field: "role"},
+	{name: "keys differing by case in a map", json: `{"role": "user", "ROLE": "admin"}`, duplicate: true},
+	{name: "nested duplicate key", json: `{"items": [{"a": 1}, {"a": 1, "a": 2}]}`, duplicate: true, kind: JSONErrDuplicateKey, field: "items[1].a"},
+	{name: "same key in sibling objects", json: `{"a": {"x": 1}, "b": {"x": 1}, "c": [{"x": 1}, {"x": 2}]}`, duplicate: true},
+	{name: "key equal to a value", json: `{"a": "b", "b": "a"}`, duplicate: true},
+	{name: "shallow enough", json: `{"a": {"b": [1]}}`, depth: 3},
+	{name: "too deep", json: `{"a": {"b": [[1]]}}`, depth: 3, kind: JSONErrTooDeep, field: "a.b[0]"},
+	{name: "short array", json: `{"a": [1, 2, 3]}`, arrayLen: 3},
+	{name: "long array", json: `{"a": [1, 2, 3, 4]}`, arrayLen: 3, kind: JSONErrArrayTooLong, field: "a"},
+}
+
+func TestTools_ReadJSONStrict(t *testing.T) {
+	for _, e := range strictJSONTests {
+		var testTools Tools
+		testTools.AllowUnknownFields = true
+		testTools.RejectDuplicateKeys = e.duplicate
+		testTools.MaxJSONDepth = e.depth
+		testTools.MaxJSONArrayLength = e.arrayLen
+
+		var decodedJSON map[string]interface{}
+		req, _ := http.NewRequest("POST", "/", strings.NewReader(e.json))
+		err := testTools.ReadJSON(httptest.NewRecorder(), req, &decodedJSON)
+
+		if e.kind == "" {
+			if err != nil {
+				t.Errorf("%s: unexpected error %v", e.name, err)
+			}
+			continue
+		}
+
+		var jsonErr *JSONError
+		if !errors.As(err, &jsonErr) || jsonErr.Kind != e.kind || jsonErr.Field != e.field {
+			t.Errorf("%s: expected a %s error on %q but got %#v", e.name, e.kind, e.field, err)
+		}
+	}
+}
+
+var duplicateKeyCaseTests = []struct {
+	name  string
+	json  string
+	field string
+}{
+	{name: "struct field", json: `{"role": "user", "ROLE": "admin"}`, field: "ROLE"},
+	{name: "nested struct field", json: `{"items": [{"sku": "a", "Sku": "b"}]}`, field: "items[0].Sku"},
+	{name: "map values", json: `{"limits": {"a": 1, "A": 2}}`},
+	{name: "interface values", json: `{"extra": {"a": 1, "A": 2}}`},
+}
+
+func TestTools_ReadJSONDuplicateKeyCase(t *testing.T) {
+	var testTools Tools
+	testTools.RejectDuplicateKeys = true
+
+	for _, e := range duplicateKeyCaseTests {
+		var decoded struct {
+			Role  string `json:"role"`
+			Items []*struct {
+				SKU string `json:"sku"`
+			} `json:"items"`
+			Limits map[string]int `json:"limits"`
+			Extra  interface{}    `json:"extra"`
+		}
+		req, _ := http.NewRequest("POST", "/", strings.NewReader(e.json))
+		err := testTools.ReadJSON(httptest.NewRecorder(), req, &decoded)
+
+		if e.field == "" {
+			if err != nil {
+				t.Errorf("%s: unexpected error %v", e.name, err)
+			}
+			continue
+		}
+
+		var jsonErr *JSONError
+		if !errors.As(err, &jsonErr) || jsonErr.Kind != JSONErrDuplicateKey || jsonErr.Field != e.field {
+			t.Errorf("%s: expected a duplicate key error on %q but got %v", e.name, e.field, err)
+		}
+	}
+}
+
+func TestTools_ReadJSONNumber(t *testing.T) {
+	var testTools Tools
+	testTools.AllowUnknownFields = true
+	testTools.UseJSONNumber = true
+
+	var decodedJSON map[string]interface{}
+	req, _ := http.NewRequest("POST", "/", strings.NewReader(`{"id": 12345678901234567890}`))
+
+	if err := testTools.ReadJSON(httptest.NewRecorder(), req, &decodedJSON); err != nil {
+		t.Fatal(err)
+	}
+
+	n, ok := decodedJSON["id"].(json.Number)
+	if !ok || n.String() != "12345678901234567890" {
+		t.Errorf("expected the exact number to be kept, got %#v", decodedJSON["id"])
+	}
+}
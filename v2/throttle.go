@@ -0,0 +1,226 @@
+package toolkit
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// maxThrottleChunk is the largest number of bytes read or written at once by the throttled readers and writers.
+const maxThrottleChunk = 32 * 1024
+
+// RateLimiter is a token bucket limiting a flow of bytes to a number of bytes per second, with bursts of up to one second
+// worth of bytes. It is safe for concurrent use, so a single RateLimiter can be shared by all requests as a global limit.
+type RateLimiter struct {
+	mu     sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+// NewRateLimiter returns a RateLimiter allowing bytesPerSecond bytes per second.
+func NewRateLimiter(bytesPerSecond int64) *RateLimiter {
+	rate := float64(bytesPerSecond)
+	if rate < 1 {
+		rate = 1
+	}
+
+	return &RateLimiter{
+		rate:   rate,
+		burst:  rate,
+		tokens: rate,
+		last:   time.Now(),
+	}
+}
+
+// WaitN blocks until n bytes may pass, or until ctx is done.
+func (l *RateLimiter) WaitN(ctx context.Context, n int) error {
+	l.mu.Lock()
+	now := time.Now()
+	l.tokens += now.Sub(l.last).Seconds() * l.rate
+	if l.tokens > l.burst {
+		l.tokens = l.burst
+	}
+	l.last = now
+	l.tokens -= float64(n)
+	wait := time.Duration(-l.tokens / l.rate * float64(time.Second))
+	l.mu.Unlock()
+
+	if wait <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// chunkSize returns the number of bytes which may be handed to every limiter at once.
+func chunkSize(limiters []*RateLimiter) int {
+	size := maxThrottleChunk
+	for _, l := range limiters {
+		if int(l.burst) < size {
+			size = int(l.burst)
+		}
+	}
+	if size < 1 {
+		size = 1
+	}
+
+	return size
+}
+
+// waitAll waits for n bytes on every limiter.
+func waitAll(ctx context.Context, limiters []*RateLimiter, n int) error {
+	for _, l := range limiters {
+		if err := l.WaitN(ctx, n); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ThrottledReader is an io.Reader whose reads are limited by one or more RateLimiters.
+type ThrottledReader struct {
+	ctx      context.Context
+	r        io.Reader
+	limiters []*RateLimiter
+}
+
+// NewThrottledReader returns a reader reading from r no faster than every one of limiters allows. Nil limiters are ignored.
+func NewThrottledReader(ctx context.Context, r io.Reader, limiters ...*RateLimiter) *ThrottledReader {
+	return &ThrottledReader{ctx: ctx, r: r, limiters: compactLimiters(limiters)}
+}
+
+// Read reads up to len(p) bytes once the limiters allow it.
+func (t *ThrottledReader) Read(p []byte) (int, error) {
+	if len(t.limiters) == 0 {
+		return t.r.Read(p)
+	}
+
+	if size := chunkSize(t.limiters); len(p) > size {
+		p = p[:size]
+	}
+
+	n, err := t.r.Read(p)
+	if n > 0 {
+		if werr := waitAll(t.ctx, t.limiters, n); werr != nil {
+			return n, werr
+		}
+	}
+
+	return n, err
+}
+
+// throttledBody is a request body whose reads are limited by a ThrottledReader.
+type throttledBody struct {
+	*ThrottledReader
+	body io.Closer
+}
+
+// Close closes the original body.
+func (b *throttledBody) Close() error {
+	return b.body.Close()
+}
+
+// ThrottledWriter is an io.Writer whose writes are limited by one or more RateLimiters.
+type ThrottledWriter struct {
+	ctx      context.Context
+	w        io.Writer
+	limiters []*RateLimiter
+}
+
+// NewThrottledWriter returns a writer writing to w no faster than every one of limiters allows. Nil limiters are ignored.
+func NewThrottledWriter(ctx context.Context, w io.Writer, limiters ...*RateLimiter) *ThrottledWriter {
+	return &ThrottledWriter{ctx: ctx, w: w, limiters: compactLimiters(limiters)}
+}
+
+// Write writes p in chunks, each one once the limiters allow it.
+func (t *ThrottledWriter) Write(p []byte) (int, error) {
+	if len(t.limiters) == 0 {
+		return t.w.Write(p)
+	}
+
+	size := chunkSize(t.limiters)
+	written := 0
+	for len(p) > 0 {
+		chunk := p
+		if len(chunk) > size {
+			chunk = chunk[:size]
+		}
+
+		if err := waitAll(t.ctx, t.limiters, len(chunk)); err != nil {
+			return written, err
+		}
+
+		n, err := t.w.Write(chunk)
+		written += n
+		if err != nil {
+			return written, err
+		}
+		p = p[n:]
+	}
+
+	return written, nil
+}
+
+// throttledResponseWriter is a http.ResponseWriter whose body is written through a ThrottledWriter.
+type throttledResponseWriter struct {
+	http.ResponseWriter
+	tw *ThrottledWriter
+}
+
+// Write writes the body through the throttled writer.
+func (t *throttledResponseWriter) Write(p []byte) (int, error) {
+	return t.tw.Write(p)
+}
+
+// Unwrap returns the original http.ResponseWriter, for use by http.ResponseController.
+func (t *throttledResponseWriter) Unwrap() http.ResponseWriter {
+	return t.ResponseWriter
+}
+
+// throttleDownload returns w limited by t.DownloadRate and t.DownloadLimiter, or w itself when neither is set.
+func (t *Tools) throttleDownload(w http.ResponseWriter, r *http.Request) http.ResponseWriter {
+	limiters := t.limiters(t.DownloadRate, t.DownloadLimiter)
+	if len(limiters) == 0 {
+		return w
+	}
+
+	return &throttledResponseWriter{ResponseWriter: w, tw: NewThrottledWriter(r.Context(), w, limiters...)}
+}
+
+// limiters returns a new per-request limiter for rate, if set, along with the shared limiter, if set.
+func (t *Tools) limiters(rate int64, shared *RateLimiter) []*RateLimiter {
+	var limiters []*RateLimiter
+	if rate > 0 {
+		limiters = append(limiters, NewRateLimiter(rate))
+	}
+	if shared != nil {
+		limiters = append(limiters, shared)
+	}
+
+	return limiters
+}
+
+// compactLimiters returns limiters without its nil elements.
+func compactLimiters(limiters []*RateLimiter) []*RateLimiter {
+	var out []*RateLimiter
+	for _, l := range limiters {
+		if l != nil {
+			out = append(out, l)
+		}
+	}
+
+	return out
+}
@@ -0,0 +1,85 @@
+package toolkit
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestThrottledWriter(t *testing.T) {
+	var out bytes.Buffer
+	w := NewThrottledWriter(context.Background(), &out, NewRateLimiter(50*1024), nil)
+
+	start := time.Now()
+	n, err := w.Write(make([]byte, 100*1024))
+	elapsed := time.Since(start)
+
+	if err != nil || n != 100*1024 || out.Len() != 100*1024 {
+		t.Fatalf("wrote %d bytes with error %v", n, err)
+	}
+
+	//	the first 50KB pass as a burst, the next 50KB take a second
+	if elapsed < 800*time.Millisecond || elapsed > 3*time.Second {
+		t.Errorf("expected the write to take about a second, took %s", elapsed)
+	}
+}
+
+func TestThrottledReader(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	r := NewThrottledReader(ctx, bytes.NewReader(make([]byte, 10*1024)), NewRateLimiter(1024))
+
+	buff := make([]byte, 4096)
+	if n, err := r.Read(buff); err != nil || n != 1024 {
+		t.Fatalf("expected a first read of one burst, got %d bytes and %v", n, err)
+	}
+
+	cancel()
+	if _, err := io.ReadAll(r); err != context.Canceled {
+		t.Errorf("expected context.Canceled but got %v", err)
+	}
+}
+
+func TestTools_DownloadThrottled(t *testing.T) {
+	var testTools Tools
+	testTools.DownloadRate = 64 * 1024
+	testTools.DownloadLimiter = NewRateLimiter(1024 * 1024)
+
+	rr := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+
+	start := time.Now()
+	testTools.DownloadStaticFile(rr, req, "./testdata/pic.jpg", "rowdy-cat.jpg")
+	elapsed := time.Since(start)
+
+	if rr.Body.Len() != 91585 {
+		t.Errorf("expected the whole file, got %d bytes", rr.Body.Len())
+	}
+
+	if elapsed < 300*time.Millisecond {
+		t.Errorf("download was not throttled, took %s", elapsed)
+	}
+}
+
+func TestTools_UploadThrottled(t *testing.T) {
+	var testTools Tools
+	testTools.UploadRate = 60 * 1024
+
+	//	the body is about 90 KB, so a burst of 60 KB leaves half a second to wait
+	start := time.Now()
+	uploaded, err := testTools.UploadOneFile(newUploadRequest(t, "./testdata/img.jpg", nil), t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if uploaded.FileSize != 91585 {
+		t.Errorf("wrong file size %d", uploaded.FileSize)
+	}
+
+	if elapsed := time.Since(start); elapsed < 400*time.Millisecond {
+		t.Errorf("upload was not throttled, it took %s", elapsed)
+	}
+}
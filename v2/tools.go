@@ -3,6 +3,8 @@ package toolkit
 import (
 	"bytes"
 	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -10,6 +12,7 @@ import (
 	"net/http"
 	"os"
 	"path/filepath"
+	"reflect"
 	"regexp"
 	"strings"
 )
@@ -18,11 +21,36 @@ import (
 const randomStringSource = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789_+"
 
 // Tools is the type to instantiate this module. Any variable of this type will have access to all the methods with *Tools.
+// Its settings, along with the schemas, codecs and message catalogs registered with RegisterSchema, RegisterCodec and
+// RegisterMessages, must be in place before it is used to handle requests, as they are read without locking.
 type Tools struct {
-	MaxFileSize        int
-	AllowedFileTypes   []string
-	MaxJsonSize        int
-	AllowUnknownFields bool
+	MaxFileSize            int
+	AllowedFileTypes       []string
+	MaxJsonSize            int
+	AllowUnknownFields     bool
+	MetadataMode           MetadataMode
+	MetadataFields         []string
+	SigningKeys            []SigningKey
+	OffloadMode            OffloadMode
+	OffloadRoot            string
+	OffloadPrefix          string
+	ServePrecompressed     bool
+	CompressDownloads      bool
+	DownloadRate           int64
+	DownloadLimiter        *RateLimiter
+	UploadRate             int64
+	UploadLimiter          *RateLimiter
+	ValidateJSON           bool
+	RequireJSONContentType bool
+	RejectDuplicateKeys    bool
+	MaxJSONDepth           int
+	MaxJSONArrayLength     int
+	UseJSONNumber          bool
+	CollectTypeErrors      bool
+
+	schemas  map[reflect.Type]*Schema
+	codecs   map[string]DecodeFunc
+	messages map[string]MessageCatalog
 }
 
 // RandomString takes in the length of the requested string and returns the random string
@@ -43,6 +71,8 @@ type UploadedFile struct {
 	NewFileName      string
 	OriginalFileName string
 	FileSize         int64
+	ContentType      string
+	SHA256           string
 }
 
 // UploadOneFile uploads one file to the given uploadDir based on the request
@@ -60,7 +90,8 @@ func (t *Tools) UploadOneFile(r *http.Request, uploadDir string, rename ...bool)
 	return files[0], nil
 }
 
-// UploadFiles uploads multiple files to given uploadDir based on the request
+// UploadFiles uploads multiple files to given uploadDir based on the request. Files which keep their own name are refused
+// when it is the name of a metadata file, as reported by IsMetadataFile.
 func (t *Tools) UploadFiles(r *http.Request, uploadDir string, rename ...bool) ([]*UploadedFile, error) {
 	renameFile := true
 	if len(rename) > 0 {
@@ -68,6 +99,7 @@ func (t *Tools) UploadFiles(r *http.Request, uploadDir string, rename ...bool) (
 	}
 
 	var uploadedFiles []*UploadedFile
+	limiters := t.limiters(t.UploadRate, t.UploadLimiter)
 
 	if t.MaxFileSize == 0 {
 		t.MaxFileSize = 1024 * 1024 * 1024
@@ -78,6 +110,11 @@ func (t *Tools) UploadFiles(r *http.Request, uploadDir string, rename ...bool) (
 		return nil, err
 	}
 
+	//	throttle the body itself, as ParseMultipartForm reads the whole of it before any file is copied
+	if len(limiters) > 0 {
+		r.Body = &throttledBody{ThrottledReader: NewThrottledReader(r.Context(), r.Body, limiters...), body: r.Body}
+	}
+
 	err = r.ParseMultipartForm(int64(t.MaxFileSize))
 	if err != nil {
 		return nil, errors.New("uploaded files are too big")
@@ -94,14 +131,14 @@ func (t *Tools) UploadFiles(r *http.Request, uploadDir string, rename ...bool) (
 				defer infile.Close()
 
 				buff := make([]byte, 512)
-				_, err = infile.Read(buff)
+				n, err := infile.Read(buff)
 				if err != nil {
 					return nil, err
 				}
 
 				//TODO: check to see if the file is permitted
 				allowed := false
-				fileType := http.DetectContentType(buff)
+				fileType := http.DetectContentType(buff[:n])
 				// allowedTypes := []string{"image/png", "image/jpeg", "image/gif"}
 
 				if len(t.AllowedFileTypes) > 0 {
@@ -124,9 +161,14 @@ func (t *Tools) UploadFiles(r *http.Request, uploadDir string, rename ...bool) (
 				}
 
 				uploadedFile.OriginalFileName = hdr.Filename
+				uploadedFile.ContentType = fileType
 				if renameFile {
 					uploadedFile.NewFileName = fmt.Sprintf("%s%s", t.RandomString(25), filepath.Ext(hdr.Filename))
 				} else {
+					//	kept names must not overwrite the sidecars or manifest of the directory
+					if IsMetadataFile(strings.ToLower(hdr.Filename)) {
+						return nil, errors.New("uploaded file name is reserved for metadata")
+					}
 					uploadedFile.NewFileName = hdr.Filename
 				}
 
@@ -136,11 +178,17 @@ func (t *Tools) UploadFiles(r *http.Request, uploadDir string, rename ...bool) (
 				if outfile, err = os.Create(filepath.Join(uploadDir, uploadedFile.NewFileName)); err != nil {
 					return nil, err
 				} else {
-					fileSize, err := io.Copy(outfile, infile)
+					hash := sha256.New()
+					fileSize, err := io.Copy(io.MultiWriter(outfile, hash), infile)
 					if err != nil {
 						return nil, err
 					}
 					uploadedFile.FileSize = fileSize
+					uploadedFile.SHA256 = hex.EncodeToString(hash.Sum(nil))
+				}
+
+				if err = t.saveMetadata(uploadDir, &uploadedFile, r.MultipartForm.Value); err != nil {
+					return nil, err
 				}
 
 				uploadedFiles = append(uploadedFiles, &uploadedFile)
@@ -190,10 +238,10 @@ func (t *Tools) Slugify(s string) (string, error) {
 }
 
 // DownloadStaticFile lets the user download a particular file saved on the server with the particular path given to it and the
-// downloaded file can be of the given name.
-func (t *Tools) DownloadStaticFile(w http.ResponseWriter, r *http.Request, pathName, displayName string) {
-	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s\"", displayName))
-	http.ServeFile(w, r, pathName)
+// downloaded file can be of the given name. If displayName is empty, the original name and content type are restored from the
+// upload metadata when it exists. pathName is used as is, so it must never come from user input; use DownloadFile for that.
+func (t *Tools) DownloadStaticFile(w http.ResponseWriter, r *http.Request, pathName, displayName string, opts ...DownloadOptions) {
+	t.serveFile(w, r, pathName, displayName, opts...)
 }
 
 // JSONResponse is the type used for sending JSON around
@@ -203,64 +251,31 @@ type JSONResponse struct {
 	Data    interface{} `json:"data,omitempty"`
 }
 
-// ReadJSON tries to read the body of a request of type json and tries to convert it to go parameter
-func (t *Tools) ReadJSON(w http.ResponseWriter, r *http.Request, data interface{}) error {
-	maxBytes := 1024 * 1024 //	1 MB
+// maxJSONBytes returns the size limit of request bodies, t.MaxJsonSize or 1 MB when it is not set.
+func (t *Tools) maxJSONBytes() int {
 	if t.MaxJsonSize != 0 {
-		maxBytes = t.MaxJsonSize
+		return t.MaxJsonSize
 	}
 
-	r.Body = http.MaxBytesReader(w, r.Body, int64(maxBytes))
-	dec := json.NewDecoder(r.Body)
+	return 1024 * 1024 //	1 MB
+}
 
-	if !t.AllowUnknownFields {
-		dec.DisallowUnknownFields()
-	}
+// ReadJSON tries to read the body of a request of type json and tries to convert it to go parameter. When
+// t.RequireJSONContentType is set, requests which are not sent as JSON are refused with a 415 JSONError. Bodies compressed
+// with gzip or deflate are decompressed, and t.MaxJsonSize limits both their compressed and decompressed size. When
+// t.ValidateJSON is set, the decoded data is checked against its validate struct tags as well. The strict mode settings
+// t.RejectDuplicateKeys, t.MaxJSONDepth and t.MaxJSONArrayLength are checked before decoding. When t.CollectTypeErrors is
+// set, a body with several values of the wrong type is refused with JSONErrors listing all of them. Fields missing from the
+// body are set from their default tag, as in `json:"per_page" default:"20"`, before validation.
+func (t *Tools) ReadJSON(w http.ResponseWriter, r *http.Request, data interface{}) error {
+	maxBytes := t.maxJSONBytes()
 
-	err := dec.Decode(data)
+	raw, err := t.readJSONBody(w, r, maxBytes)
 	if err != nil {
-		var syntaxError *json.SyntaxError
-		var unmarshalTypeError *json.UnmarshalTypeError
-		var invalidUnmarchaError *json.InvalidUnmarshalError
-
-		switch {
-		case errors.As(err, &syntaxError):
-			return fmt.Errorf("body contains badly-formed JSON (at character %d)", syntaxError.Offset)
-
-		case errors.Is(err, io.ErrUnexpectedEOF):
-			return errors.New("body contains badly-formed JSON")
-
-		case errors.As(err, &unmarshalTypeError):
-			if unmarshalTypeError.Field != "" {
-				return fmt.Errorf("body contains incorrect JSON type for field %q", unmarshalTypeError.Field)
-			}
-			return fmt.Errorf("body containes incorrect JSON (at character %d)", unmarshalTypeError.Offset)
-
-		case errors.Is(err, io.EOF):
-			return errors.New("body must not be empty")
-
-		//	This wont happen is DisallowUnknownFields is set to true
-		case strings.HasPrefix(err.Error(), "json: unknown field"):
-			fieldName := strings.TrimPrefix(err.Error(), "json: unknown field")
-			return fmt.Errorf("body contains unknown key %s", fieldName)
-
-		case err.Error() == "http: request body too large":
-			return fmt.Errorf("body must not be larger than %d bytes", maxBytes)
-
-		case errors.As(err, &invalidUnmarchaError):
-			return fmt.Errorf("error unmarshalling JSON: %s", err.Error())
-
-		default:
-			return err
-		}
-	}
-
-	err = dec.Decode(&struct{}{})
-	if err != io.EOF {
-		return errors.New("body must contain only one JSON value")
+		return err
 	}
 
-	return nil
+	return t.decodeJSON(raw, data, maxBytes)
 }
 
 // WriteJSON takes a response status code and arbitrary data and writes json to the client
@@ -289,17 +304,35 @@ func (t *Tools) WriteJSON(w http.ResponseWriter, status int, data interface{}, h
 	return nil
 }
 
-// ErrorJSON takes an error and optionally a status code, and generates and sends a JSON error message
+// ErrorJSON takes an error and optionally a status code, and generates and sends a JSON error message. Without a status
+// code, errors such as JSONError and ValidationErrors which have a StatusCode method pick their own, and any other error is
+// sent as a 400. ValidationErrors and JSONErrors are sent with a map from field path to message as data.
 func (t *Tools) ErrorJSON(w http.ResponseWriter, err error, status ...int) error {
+	return t.writeErrorJSON(w, err, nil, status...)
+}
+
+// writeErrorJSON sends err as ErrorJSON does, with its messages translated by the first of catalogs which has them.
+func (t *Tools) writeErrorJSON(w http.ResponseWriter, err error, catalogs []MessageCatalog, status ...int) error {
 	statusCode := http.StatusBadRequest
 
+	var coder interface{ StatusCode() int }
 	if len(status) > 0 {
 		statusCode = status[0]
+	} else if errors.As(err, &coder) {
+		statusCode = coder.StatusCode()
 	}
 
 	var payload JSONResponse
 	payload.Error = true
-	payload.Message = err.Error()
+	payload.Message = localize(catalogs, err)
+
+	var validationErrors ValidationErrors
+	var jsonErrors JSONErrors
+	if errors.As(err, &validationErrors) {
+		payload.Data = validationErrors.localizedMap(catalogs)
+	} else if errors.As(err, &jsonErrors) {
+		payload.Data = jsonErrors.localizedMap(catalogs)
+	}
 
 	return t.WriteJSON(w, statusCode, payload)
 }
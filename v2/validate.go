@@ -0,0 +1,264 @@
+package toolkit
+
+import (
+	"fmt"
+	"net/mail"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// FieldError is a rule of a validate struct tag which a field does not satisfy.
+type FieldError struct {
+	// Field is the JSON path of the field, such as items[2].qty, or its JSON Pointer, such as /items/2/qty, when the
+	// error comes from a Schema.
+	Field string
+	// Rule is the name of the rule, such as required or min, or the schema keyword.
+	Rule string
+	// Param is the parameter of the rule, such as 3 in min=3.
+	Param string
+	// Message describes the error in English.
+	Message string
+}
+
+// Error returns the path of the field followed by the message.
+func (e FieldError) Error() string {
+	return fmt.Sprintf("%s %s", e.Field, e.Message)
+}
+
+// ValidationErrors holds every FieldError found by Validate. ErrorJSON sends it as a map from field path to message.
+type ValidationErrors []FieldError
+
+// Error joins the errors of every field.
+func (v ValidationErrors) Error() string {
+	msgs := make([]string, len(v))
+	for i, e := range v {
+		msgs[i] = e.Error()
+	}
+
+	return strings.Join(msgs, "; ")
+}
+
+// Map returns the messages keyed by the path of their field. Only the first error of a field is kept.
+func (v ValidationErrors) Map() map[string]string {
+	return v.localizedMap(nil)
+}
+
+// localizedMap returns the messages keyed by the path of their field, translated by the first of catalogs which has them.
+func (v ValidationErrors) localizedMap(catalogs []MessageCatalog) map[string]string {
+	m := make(map[string]string, len(v))
+	for _, e := range v {
+		if _, ok := m[e.Field]; !ok {
+			m[e.Field] = translate(catalogs, "validate."+e.Rule, map[string]interface{}{"field": e.Field, "param": e.Param}, e.Message)
+		}
+	}
+
+	return m
+}
+
+// Validate checks data, which must be a struct or a pointer to one, against the validate tags of its fields, going through
+// nested structs, slices and maps. Fields are named after their JSON names. The rules are required, omitempty, min, max,
+// len, email, url and oneof, separated by commas as in `validate:"required,min=3,oneof=a b c"`. With omitempty, the other
+// rules are skipped when the field has its zero value. All failing fields are returned at once as ValidationErrors.
+func (t *Tools) Validate(data interface{}) error {
+	var errs ValidationErrors
+	if err := validateValue(reflect.ValueOf(data), "", &errs); err != nil {
+		return err
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+
+	return nil
+}
+
+// validateValue walks v, validating the tagged fields of every struct found under the JSON path p.
+func validateValue(v reflect.Value, p string, errs *ValidationErrors) error {
+	for v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+
+	switch v.Kind() {
+	case reflect.Struct:
+		typ := v.Type()
+		for i := 0; i < typ.NumField(); i++ {
+			sf := typ.Field(i)
+			if !sf.IsExported() {
+				continue
+			}
+
+			name, ok := jsonFieldName(sf)
+			if !ok {
+				continue
+			}
+
+			fieldPath := p
+			if !sf.Anonymous || sf.Tag.Get("json") != "" {
+				fieldPath = joinPath(p, name)
+			}
+
+			if tag := sf.Tag.Get("validate"); tag != "" && tag != "-" {
+				if err := validateField(v.Field(i), fieldPath, tag, errs); err != nil {
+					return err
+				}
+			}
+
+			if err := validateValue(v.Field(i), fieldPath, errs); err != nil {
+				return err
+			}
+		}
+
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			if err := validateValue(v.Index(i), fmt.Sprintf("%s[%d]", p, i), errs); err != nil {
+				return err
+			}
+		}
+
+	case reflect.Map:
+		iter := v.MapRange()
+		for iter.Next() {
+			if err := validateValue(iter.Value(), joinPath(p, fmt.Sprint(iter.Key().Interface())), errs); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// validateField checks the value of a single field against the rules of its validate tag.
+func validateField(v reflect.Value, p, tag string, errs *ValidationErrors) error {
+	rules := strings.Split(tag, ",")
+
+	if v.IsZero() {
+		for _, rule := range rules {
+			switch strings.TrimSpace(rule) {
+			case "required":
+				*errs = append(*errs, FieldError{Field: p, Rule: "required", Message: "is required"})
+				return nil
+			case "omitempty":
+				return nil
+			}
+		}
+	}
+
+	for v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+
+	for _, rule := range rules {
+		name, param, _ := strings.Cut(strings.TrimSpace(rule), "=")
+
+		var msg string
+		var err error
+
+		switch name {
+		case "", "required", "omitempty":
+			continue
+		case "min":
+			msg, err = checkBound(v, param, func(n, bound float64) bool { return n >= bound }, "at least")
+		case "max":
+			msg, err = checkBound(v, param, func(n, bound float64) bool { return n <= bound }, "at most")
+		case "len":
+			msg, err = checkBound(v, param, func(n, bound float64) bool { return n == bound }, "exactly")
+		case "email":
+			if addr, perr := mail.ParseAddress(v.String()); perr != nil || addr.Address != v.String() {
+				msg = "must be a valid email address"
+			}
+		case "url":
+			if u, perr := url.ParseRequestURI(v.String()); perr != nil || u.Scheme == "" || u.Host == "" {
+				msg = "must be a valid URL"
+			}
+		case "oneof":
+			options := strings.Fields(param)
+			found := false
+			for _, o := range options {
+				if fmt.Sprint(v.Interface()) == o {
+					found = true
+				}
+			}
+			if !found {
+				msg = fmt.Sprintf("must be one of %s", strings.Join(options, ", "))
+			}
+		default:
+			return fmt.Errorf("unknown validation rule %q on %s", name, p)
+		}
+
+		if err != nil {
+			return fmt.Errorf("invalid validation rule %q on %s: %w", rule, p, err)
+		}
+
+		if msg != "" {
+			*errs = append(*errs, FieldError{Field: p, Rule: name, Param: param, Message: msg})
+		}
+	}
+
+	return nil
+}
+
+// checkBound compares the size of v with the bound in param. Strings are measured in characters, slices and maps in items
+// and numbers by their value. It returns the message to report when ok returns false.
+func checkBound(v reflect.Value, param string, ok func(n, bound float64) bool, relation string) (string, error) {
+	bound, err := strconv.ParseFloat(param, 64)
+	if err != nil {
+		return "", err
+	}
+
+	var n float64
+	format := "must be %s %s"
+
+	switch v.Kind() {
+	case reflect.String:
+		n, format = float64(utf8.RuneCountInString(v.String())), "must be %s %s characters long"
+	case reflect.Slice, reflect.Array, reflect.Map:
+		n, format = float64(v.Len()), "must contain %s %s items"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n = float64(v.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n = float64(v.Uint())
+	case reflect.Float32, reflect.Float64:
+		n = v.Float()
+	default:
+		return "", fmt.Errorf("can not be applied to %s", v.Kind())
+	}
+
+	if ok(n, bound) {
+		return "", nil
+	}
+
+	return fmt.Sprintf(format, relation, param), nil
+}
+
+// jsonFieldName returns the name of a struct field in JSON, and false if the field is skipped by encoding/json.
+func jsonFieldName(sf reflect.StructField) (string, bool) {
+	tag := sf.Tag.Get("json")
+	if tag == "-" {
+		return "", false
+	}
+
+	name, _, _ := strings.Cut(tag, ",")
+	if name == "" {
+		name = sf.Name
+	}
+
+	return name, true
+}
+
+// joinPath appends the field name to the JSON path p.
+func joinPath(p, name string) string {
+	if p == "" {
+		return name
+	}
+
+	return p + "." + name
+}
@@ -0,0 +1,138 @@
+package toolkit
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type validateItem struct {
+	SKU string `json:"sku" validate:"required,len=4"`
+	Qty int    `json:"qty" validate:"min=1,max=10"`
+}
+
+type validateOrder struct {
+	Name    string         `json:"name" validate:"required,min=3,max=10"`
+	Email   string         `json:"email" validate:"omitempty,email"`
+	Website string         `json:"website" validate:"omitempty,url"`
+	Status  string         `json:"status" validate:"omitempty,oneof=new paid"`
+	Items   []validateItem `json:"items" validate:"required,max=3"`
+	Address *struct {
+		City string `json:"city" validate:"required"`
+	} `json:"address"`
+	Note string `json:"-" validate:"required"`
+}
+
+var validateTests = []struct {
+	name     string
+	json     string
+	expected map[string]string
+}{
+	{
+		name:     "valid",
+		json:     `{"name": "Jürgen", "email": "j@example.com", "website": "https://example.com", "status": "paid", "items": [{"sku": "ABCD", "qty": 2}]}`,
+		expected: map[string]string{},
+	},
+	{
+		name: "missing fields",
+		json: `{}`,
+		expected: map[string]string{
+			"name":  "is required",
+			"items": "is required",
+		},
+	},
+	{
+		name: "invalid fields",
+		json: `{"name": "Jo", "email": "not an email", "website": "example.com", "status": "lost", "items": [{"sku": "ABCD", "qty": 1}, {"sku": "AB", "qty": 1}, {"sku": "ABCD", "qty": 11}], "address": {}}`,
+		expected: map[string]string{
+			"name":         "must be at least 3 characters long",
+			"email":        "must be a valid email address",
+			"website":      "must be a valid URL",
+			"status":       "must be one of new, paid",
+			"items[1].sku": "must be exactly 4 characters long",
+			"items[2].qty": "must be at most 10",
+			"address.city": "is required",
+		},
+	},
+}
+
+func TestTools_Validate(t *testing.T) {
+	var testTools Tools
+
+	for _, e := range validateTests {
+		var order validateOrder
+		if err := json.Unmarshal([]byte(e.json), &order); err != nil {
+			t.Fatal(err)
+		}
+
+		err := testTools.Validate(&order)
+
+		var validationErrors ValidationErrors
+		if len(e.expected) == 0 {
+			if err != nil {
+				t.Errorf("%s: unexpected error %s", e.name, err)
+			}
+			continue
+		}
+		if !errors.As(err, &validationErrors) {
+			t.Fatalf("%s: expected ValidationErrors but got %v", e.name, err)
+		}
+
+		got := validationErrors.Map()
+		if len(got) != len(e.expected) {
+			t.Errorf("%s: expected %v but got %v", e.name, e.expected, got)
+		}
+		for field, msg := range e.expected {
+			if got[field] != msg {
+				t.Errorf("%s: expected %s to be %q but got %q", e.name, field, msg, got[field])
+			}
+		}
+	}
+}
+
+func TestTools_ValidateUnknownRule(t *testing.T) {
+	var testTools Tools
+
+	var data struct {
+		Foo string `validate:"shiny"`
+	}
+	data.Foo = "bar"
+
+	if err := testTools.Validate(data); err == nil || errors.As(err, new(ValidationErrors)) {
+		t.Errorf("expected a plain error for an unknown rule, got %v", err)
+	}
+}
+
+func TestTools_ReadJSONValidate(t *testing.T) {
+	var testTools Tools
+	testTools.ValidateJSON = true
+
+	var order validateOrder
+	req, _ := http.NewRequest("POST", "/", strings.NewReader(`{"name": "Jo", "items": [{"sku": "ABCD", "qty": 0}]}`))
+	rr := httptest.NewRecorder()
+
+	err := testTools.ReadJSON(rr, req, &order)
+	if err == nil {
+		t.Fatal("expected validation errors")
+	}
+
+	if err := testTools.ErrorJSON(rr, err); err != nil {
+		t.Fatal(err)
+	}
+
+	var payload struct {
+		Error bool              `json:"error"`
+		Data  map[string]string `json:"data"`
+	}
+	if err := json.NewDecoder(rr.Body).Decode(&payload); err != nil {
+		t.Fatal(err)
+	}
+
+	if !payload.Error || payload.Data["name"] == "" || payload.Data["items[0].qty"] != "must be at least 1" {
+		t.Errorf("wrong error payload: %s", fmt.Sprint(payload))
+	}
+}
@@ -0,0 +1,165 @@
+package toolkit
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// WebhookSignature describes how the sender of a webhook signs its requests with HMAC-SHA256. The zero value, apart from
+// Secret, matches the X-Hub-Signature-256 header used by GitHub.
+type WebhookSignature struct {
+	// Secret is the key shared with the sender.
+	Secret []byte
+	// Header holds the hex encoded signature. It defaults to X-Hub-Signature-256, with a default Prefix of "sha256=".
+	Header string
+	// Prefix comes before the signature in Header, such as "sha256=" or "v0=".
+	Prefix string
+	// TimestampHeader holds the Unix time the request was signed at. When set, the timestamp is signed along with the
+	// body, as laid out by Payload, and requests signed more than Tolerance away from now are refused as replays.
+	TimestampHeader string
+	// Tolerance is the replay window of timestamped signatures. It defaults to 5 minutes.
+	Tolerance time.Duration
+	// Payload builds the signed content of timestamped signatures. It defaults to the timestamp, a dot, then the body.
+	Payload func(timestamp string, body []byte) []byte
+}
+
+// ReadJSONRaw reads the body of r into data as ReadJSON does, and also returns the body exactly as it was received,
+// before any decompression or charset conversion, for instance to verify its signature. The body is still limited to
+// MaxJsonSize bytes, or 1 MB.
+func (t *Tools) ReadJSONRaw(w http.ResponseWriter, r *http.Request, data interface{}) ([]byte, error) {
+	raw, err := t.captureBody(w, r)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = t.ReadJSON(w, r, data); err != nil {
+		return nil, err
+	}
+
+	return raw, nil
+}
+
+// ReadSignedJSON reads the body of r, checks its signature with VerifyWebhookSignature, and only then decodes it into data
+// as ReadJSON does. It returns the body exactly as it was received.
+func (t *Tools) ReadSignedJSON(w http.ResponseWriter, r *http.Request, data interface{}, sig WebhookSignature) ([]byte, error) {
+	raw, err := t.captureBody(w, r)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = t.VerifyWebhookSignature(r, raw, sig); err != nil {
+		return nil, err
+	}
+
+	if err = t.ReadJSON(w, r, data); err != nil {
+		return nil, err
+	}
+
+	return raw, nil
+}
+
+// SignatureError is the error of a webhook whose signature could not be verified. Err is ErrInvalidSignature or
+// ErrSignatureExpired, which errors.Is still recognizes.
+type SignatureError struct {
+	Err error
+}
+
+// Error returns the message of the underlying error.
+func (e *SignatureError) Error() string {
+	return e.Err.Error()
+}
+
+// Unwrap returns the underlying error.
+func (e *SignatureError) Unwrap() error {
+	return e.Err
+}
+
+// StatusCode returns 401, as the sender of the webhook could not be authenticated.
+func (e *SignatureError) StatusCode() int {
+	return http.StatusUnauthorized
+}
+
+// VerifyWebhookSignature checks that the signature sent with r as described by sig matches body. Several signatures may
+// be sent in the header, separated by commas or spaces, for instance while the sender rotates its secret; one matching is
+// enough. It returns a SignatureError wrapping ErrInvalidSignature when the signature is missing or wrong, and
+// ErrSignatureExpired when a timestamped signature is outside of its replay window.
+func (t *Tools) VerifyWebhookSignature(r *http.Request, body []byte, sig WebhookSignature) error {
+	if len(sig.Secret) == 0 {
+		return ErrNoSigningKey
+	}
+
+	header, prefix := sig.Header, sig.Prefix
+	if header == "" {
+		header = "X-Hub-Signature-256"
+		if prefix == "" {
+			prefix = "sha256="
+		}
+	}
+
+	signed := body
+	if sig.TimestampHeader != "" {
+		timestamp := r.Header.Get(sig.TimestampHeader)
+		seconds, err := strconv.ParseInt(timestamp, 10, 64)
+		if err != nil {
+			return &SignatureError{Err: ErrInvalidSignature}
+		}
+
+		tolerance := sig.Tolerance
+		if tolerance <= 0 {
+			tolerance = 5 * time.Minute
+		}
+		if age := time.Since(time.Unix(seconds, 0)); age > tolerance || age < -tolerance {
+			return &SignatureError{Err: ErrSignatureExpired}
+		}
+
+		if sig.Payload != nil {
+			signed = sig.Payload(timestamp, body)
+		} else {
+			signed = append([]byte(timestamp+"."), body...)
+		}
+	}
+
+	mac := hmac.New(sha256.New, sig.Secret)
+	mac.Write(signed)
+	expected := mac.Sum(nil)
+
+	candidates := strings.FieldsFunc(r.Header.Get(header), func(c rune) bool { return c == ',' || c == ' ' })
+	for _, candidate := range candidates {
+		encoded, ok := strings.CutPrefix(candidate, prefix)
+		if !ok {
+			continue
+		}
+
+		signature, err := hex.DecodeString(encoded)
+		if err == nil && hmac.Equal(signature, expected) {
+			return nil
+		}
+	}
+
+	return &SignatureError{Err: ErrInvalidSignature}
+}
+
+// captureBody reads the whole body of r, limited to MaxJsonSize bytes, or 1 MB, and puts it back so it can be read again.
+func (t *Tools) captureBody(w http.ResponseWriter, r *http.Request) ([]byte, error) {
+	maxBytes := t.maxJSONBytes()
+
+	r.Body = http.MaxBytesReader(w, r.Body, int64(maxBytes))
+
+	raw, err := io.ReadAll(r.Body)
+	if err != nil {
+		if jsonErr := newJSONError(err, maxBytes, raw); jsonErr != nil {
+			return nil, jsonErr
+		}
+		return nil, err
+	}
+	r.Body = io.NopCloser(bytes.NewReader(raw))
+
+	return raw, nil
+}
@@ -0,0 +1,146 @@
+package toolkit
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// hmacHex returns the hex encoded HMAC-SHA256 of content.
+func hmacHex(secret, content string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(content))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func TestTools_ReadJSONRaw(t *testing.T) {
+	var testTools Tools
+
+	var buf bytes.Buffer
+	gw := gzip.NewWriter(&buf)
+	_, _ = gw.Write([]byte(`{"foo": "bar"}`))
+	gw.Close()
+	compressed := buf.Bytes()
+
+	req, _ := http.NewRequest("POST", "/", bytes.NewReader(compressed))
+	req.Header.Set("Content-Encoding", "gzip")
+
+	var decoded struct {
+		Foo string `json:"foo"`
+	}
+	raw, err := testTools.ReadJSONRaw(httptest.NewRecorder(), req, &decoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if decoded.Foo != "bar" {
+		t.Errorf("wrong decoded value %+v", decoded)
+	}
+
+	if !bytes.Equal(raw, compressed) {
+		t.Error("raw body is not the body as received")
+	}
+
+	testTools.MaxJsonSize = 5
+	req, _ = http.NewRequest("POST", "/", strings.NewReader(`{"foo": "bar"}`))
+
+	var jsonErr *JSONError
+	if _, err = testTools.ReadJSONRaw(httptest.NewRecorder(), req, &decoded); !errors.As(err, &jsonErr) || jsonErr.Kind != JSONErrTooLarge {
+		t.Errorf("expected a too large error but got %v", err)
+	}
+}
+
+var webhookTests = []struct {
+	name    string
+	sig     WebhookSignature
+	headers map[string]string
+	err     error
+}{
+	{name: "hub signature", sig: WebhookSignature{Secret: []byte("secret")},
+		headers: map[string]string{"X-Hub-Signature-256": "sha256=" + hmacHex("secret", `{"action":"opened"}`)}},
+	{name: "wrong secret", sig: WebhookSignature{Secret: []byte("secret")},
+		headers: map[string]string{"X-Hub-Signature-256": "sha256=" + hmacHex("other", `{"action":"opened"}`)}, err: ErrInvalidSignature},
+	{name: "missing prefix", sig: WebhookSignature{Secret: []byte("secret")},
+		headers: map[string]string{"X-Hub-Signature-256": hmacHex("secret", `{"action":"opened"}`)}, err: ErrInvalidSignature},
+	{name: "missing signature", sig: WebhookSignature{Secret: []byte("secret")}, err: ErrInvalidSignature},
+	{name: "rotated secret", sig: WebhookSignature{Secret: []byte("secret"), Header: "X-Signature"},
+		headers: map[string]string{"X-Signature": hmacHex("old", `{"action":"opened"}`) + "," + hmacHex("secret", `{"action":"opened"}`)}},
+	{name: "no secret", err: ErrNoSigningKey},
+	{name: "timestamped", sig: WebhookSignature{Secret: []byte("secret"), Header: "X-Signature", TimestampHeader: "X-Timestamp"},
+		headers: map[string]string{"X-Timestamp": "{now}", "X-Signature": "{now}."}},
+	{name: "timestamp not signed", sig: WebhookSignature{Secret: []byte("secret"), Header: "X-Signature", TimestampHeader: "X-Timestamp"},
+		headers: map[string]string{"X-Timestamp": "{now}", "X-Signature": hmacHex("secret", `{"action":"opened"}`)}, err: ErrInvalidSignature},
+	{name: "replayed", sig: WebhookSignature{Secret: []byte("secret"), Header: "X-Signature", TimestampHeader: "X-Timestamp"},
+		headers: map[string]string{"X-Timestamp": "{old}", "X-Signature": "{old}."}, err: ErrSignatureExpired},
+	{name: "custom payload", sig: WebhookSignature{Secret: []byte("secret"), Header: "X-Slack-Signature", Prefix: "v0=", TimestampHeader: "X-Slack-Request-Timestamp",
+		Payload: func(timestamp string, body []byte) []byte { return []byte("v0:" + timestamp + ":" + string(body)) }},
+		headers: map[string]string{"X-Slack-Request-Timestamp": "{now}", "X-Slack-Signature": "v0=" + "{now}:"}},
+}
+
+func TestTools_ReadSignedJSON(t *testing.T) {
+	body := `{"action":"opened"}`
+	now := strconv.FormatInt(time.Now().Unix(), 10)
+	old := strconv.FormatInt(time.Now().Add(-time.Hour).Unix(), 10)
+
+	for _, e := range webhookTests {
+		var testTools Tools
+
+		req, _ := http.NewRequest("POST", "/", strings.NewReader(body))
+		for k, v := range e.headers {
+			//	signatures of timestamped requests are computed here, once the timestamp is known
+			switch v {
+			case "{now}":
+				v = now
+			case "{old}":
+				v = old
+			case "{now}.":
+				v = hmacHex("secret", now+"."+body)
+			case "{old}.":
+				v = hmacHex("secret", old+"."+body)
+			case "v0={now}:":
+				v = "v0=" + hmacHex("secret", "v0:"+now+":"+body)
+			}
+			req.Header.Set(k, v)
+		}
+
+		var decoded struct {
+			Action string `json:"action"`
+		}
+		raw, err := testTools.ReadSignedJSON(httptest.NewRecorder(), req, &decoded, e.sig)
+
+		if e.err != nil {
+			if !errors.Is(err, e.err) {
+				t.Errorf("%s: expected %v but got %v", e.name, e.err, err)
+			}
+			if e.err != ErrNoSigningKey {
+				rr := httptest.NewRecorder()
+				_ = testTools.ErrorJSON(rr, err)
+				if rr.Code != http.StatusUnauthorized {
+					t.Errorf("%s: expected status %d but got %d", e.name, http.StatusUnauthorized, rr.Code)
+				}
+			}
+			if decoded.Action != "" {
+				t.Errorf("%s: body decoded despite a bad signature", e.name)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("%s: unexpected error %v", e.name, err)
+			continue
+		}
+
+		if decoded.Action != "opened" || string(raw) != body {
+			t.Errorf("%s: wrong result %+v, %s", e.name, decoded, raw)
+		}
+	}
+}